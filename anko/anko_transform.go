@@ -2,92 +2,134 @@ package anko
 
 import (
 	"context"
+	_ "embed"
 	"fmt"
 
+	"github.com/mattn/anko/ast"
 	"github.com/mattn/anko/env"
 	_ "github.com/mattn/anko/packages" // Protect Me, O My Lord
+	"github.com/mattn/anko/parser"
 	"github.com/mattn/anko/vm"
 	"github.com/sudo-suhas/xgo/errors"
-	"google.golang.org/protobuf/types/known/anypb"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/structmap"
 )
 
-var script = `
-strings = import("strings")
+//go:embed default.ank
+var defaultScript string
 
-func merge(m1, m2) {
-	for k, v in m2 {
-		m1[k] = v
-	}
-	return m1
+func init() {
+	engine.Register("anko", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
 }
 
-asset.Labels = merge({"script_engine": "anko"}, asset.Labels)
-
-for e in data.Entities {
-	e.Labels = merge({"catch_phrase": "Take your stinking paws off me, you damn dirty ape!"}, e.Labels)
+// Transformer is safe for concurrent use. Each call to T runs the script
+// in a copy of an environment which is initialised once, so that the
+// variables defined or modified by one run are not seen by another.
+//
+// The script works on the asset as the map returned by structmap.Encode,
+// keyed by the proto names of the fields and with timestamps as
+// time.Time, which is decoded into the asset after the script has run.
+type Transformer struct {
+	env    *env.Env
+	urler  func(string) string
+	stmt   ast.Stmt
+	limits engine.Limits
 }
 
-for f in data.Features {
-	if f.Name == "ongoing_placed_and_waiting_acceptance_orders" || f.Name == "ongoing_orders" {
-		f.EntityName = "customer_orders"
-	} else if f.Name == "merchant_avg_dispatch_arrival_time_10m" {
-		f.EntityName = "merchant_driver"
-	} else if f.Name == "ongoing_accepted_orders" {
-		f.EntityName = "merchant_orders"
-	}
-}
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "anko.New"
 
-o = make(Owner)
-o.Name = "Big Mom"
-o.Email = "big.mom@wholecakeisland.com"
-asset.Owners += o
+	if err := opts.Limits.Supported("anko", engine.LimitMaxSteps); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-asset.Url = urler(asset.Name)
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-for u in asset.Lineage.Upstreams {
-	if u.Urn != "kafka" {
-		continue
+	stmt, err := parser.ParseSrc(script)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("parse script"), errors.WithErr(err))
 	}
-	u.Urn = strings.Replace(u.Urn, ".yonkou.io", "", -1)
-}
-`
 
-type Transformer struct {
-	URLer func(string) string
+	e := env.NewEnv()
+	for name, v := range map[string]interface{}{
+		"println": func(a ...interface{}) (int, error) { return fmt.Fprintln(opts.Printer(), a...) },
+	} {
+		if err := e.Define(name, v); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
+	return &Transformer{env: e, urler: opts.URLer, stmt: stmt, limits: opts.Limits}, nil
 }
 
-func (t *Transformer) T(ctx context.Context, a *asset.Asset) error {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "anko.Transform"
 
-	data, err := a.Data.UnmarshalNew()
+	defer engine.Recover(op, "anko", a.GetUrn(), &err)
+
+	a, commit := engine.Stage(a)
+
+	wrapper, err := structmap.NewAssetWrapper(a)
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	e := env.NewEnv()
+	m, err := wrapper.Encode(structmap.Format{Time: structmap.TimeGo})
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	// anko turns a panic in the URLer into an error of the script.
+	trap := engine.NewTrap(op, "anko", a.GetUrn())
+	e := t.env.Copy()
 	for name, v := range map[string]interface{}{
-		"asset":   a,
-		"data":    data,
-		"println": fmt.Println,
-		"urler":   t.URLer,
+		"asset": m,
+		"urler": trap.URLer(t.urler),
 	} {
-		if err := e.DefineGlobal(name, v); err != nil {
+		if err := e.Define(name, v); err != nil {
 			return errors.E(errors.WithOp(op), errors.WithErr(err))
 		}
 	}
-	if err := e.DefineType("Owner", &asset.Owner{}); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	// anko checks the context before each statement, which counts the
+	// steps.
+	runCtx, stepsExceeded := ctx, func() bool { return false }
+	if t.limits.MaxSteps != 0 {
+		runCtx, stepsExceeded = engine.CountSteps(ctx, t.limits.MaxSteps)
 	}
-	if _, err := vm.ExecuteContext(ctx, e, nil, script); err != nil {
+	if _, err := vm.RunContext(runCtx, e, nil, t.stmt); err != nil {
+		if err := trap.Err(); err != nil {
+			return err
+		}
+		if stepsExceeded() {
+			return errors.E(errors.WithOp(op), errors.WithErr(
+				engine.LimitExceeded(engine.LimitMaxSteps, t.limits.MaxSteps, err),
+			))
+		}
+		if err := engine.ContextError(ctx); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
 		return errors.E(errors.WithOp(op), errors.WithText("execute script"), errors.WithErr(err))
 	}
 
-	a.Data, err = anypb.New(data)
-	if err != nil {
+	if err := wrapper.OverwriteWith(m); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("decode map"), errors.WithErr(err))
+	}
+
+	if err := t.limits.CheckOutput(a); err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	commit()
+
 	return nil
 }
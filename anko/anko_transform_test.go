@@ -0,0 +1,40 @@
+package anko_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/play-script-engine/anko"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+func TestTransformerTime(t *testing.T) {
+	tr, err := anko.New(engine.Options{Script: engine.ScriptString(`
+time = import("time")
+asset.data.update_time = asset.data.create_time.Add(time.Hour)
+`)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	var data asset.FeatureTable
+	if err := a.GetData().UnmarshalTo(&data); err != nil {
+		t.Fatalf("UnmarshalTo() error = %v", err)
+	}
+	want := data.GetCreateTime().AsTime().Add(time.Hour)
+	if got := data.GetUpdateTime().AsTime(); !got.Equal(want) {
+		t.Errorf("T() update_time = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+// Package assetio reads assets encoded as protojson, YAML or binary
+// protobuf.
+package assetio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Format is the encoding of the asset.
+type Format string
+
+const (
+	// FormatJSON is the protojson encoding. The type of asset.data is
+	// resolved using the "@type" field.
+	FormatJSON Format = "json"
+
+	// FormatYAML is the YAML equivalent of FormatJSON.
+	FormatYAML Format = "yaml"
+
+	// FormatBinary is the protobuf wire format.
+	FormatBinary Format = "binary"
+)
+
+// Formats is the list of supported formats.
+var Formats = []Format{FormatJSON, FormatYAML, FormatBinary}
+
+// ParseFormat returns the Format with the given name.
+func ParseFormat(s string) (Format, error) {
+	const op = "assetio.ParseFormat"
+
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+
+	return "", errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithTextf("unknown format: %s", s))
+}
+
+// FormatFromPath infers the Format from the extension of the file. It
+// defaults to FormatJSON.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+
+	case ".pb", ".bin", ".binpb":
+		return FormatBinary
+
+	default:
+		return FormatJSON
+	}
+}
+
+// ReadFile reads the asset from the file at the given path. The asset is
+// read from stdin if the path is "-".
+func ReadFile(path string, f Format) (*asset.Asset, error) {
+	const op = "assetio.ReadFile"
+
+	if path == "-" {
+		return Read(os.Stdin, f)
+	}
+
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+	defer fh.Close()
+
+	a, err := Read(fh, f)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithTextf("read %s", path), errors.WithErr(err))
+	}
+
+	return a, nil
+}
+
+// Read reads the asset from r until EOF.
+func Read(r io.Reader, f Format) (*asset.Asset, error) {
+	const op = "assetio.Read"
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	a, err := Unmarshal(b, f)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return a, nil
+}
+
+// Unmarshal decodes the asset from b.
+func Unmarshal(b []byte, f Format) (*asset.Asset, error) {
+	const op = "assetio.Unmarshal"
+
+	var a asset.Asset
+	switch f {
+	case FormatJSON:
+		if err := protojson.Unmarshal(b, &a); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+
+	case FormatYAML:
+		data, err := yamlToJSON(b)
+		if err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+
+		if err := protojson.Unmarshal(data, &a); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+
+	case FormatBinary:
+		if err := proto.Unmarshal(b, &a); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+
+	default:
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithTextf("unknown format: %s", f))
+	}
+
+	return &a, nil
+}
+
+func yamlToJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonCompatible(v))
+}
+
+// jsonCompatible converts the maps with non-string keys, which can be
+// produced by the YAML decoder, into maps with string keys.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = jsonCompatible(val)
+		}
+		return v
+
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+
+	case []interface{}:
+		for i, val := range v {
+			v[i] = jsonCompatible(val)
+		}
+		return v
+
+	default:
+		return v
+	}
+}
@@ -0,0 +1,133 @@
+package assetio_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// encodings returns the sample asset with its encoding in each format, by
+// the extension of a file holding it.
+func encodings(t *testing.T) (*asset.Asset, map[string][]byte) {
+	t.Helper()
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	j, err := protojson.Marshal(a)
+	if err != nil {
+		t.Fatalf("protojson.Marshal() error = %v", err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(j, &v); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	y, err := yaml.Marshal(v)
+	if err != nil {
+		t.Fatalf("yaml.Marshal() error = %v", err)
+	}
+	b, err := proto.Marshal(a)
+	if err != nil {
+		t.Fatalf("proto.Marshal() error = %v", err)
+	}
+
+	return a, map[string][]byte{".json": j, ".yaml": y, ".binpb": b}
+}
+
+// checkEqual compares the assets and their unpacked data, the result of
+// what. The data cannot be compared packed, the order of the map entries in
+// the bytes of an Any is not deterministic.
+func checkEqual(t *testing.T, what string, got, want *asset.Asset) {
+	t.Helper()
+
+	if got.GetData().GetTypeUrl() != want.GetData().GetTypeUrl() {
+		t.Fatalf("%s data type = %s, want %s", what, got.GetData().GetTypeUrl(), want.GetData().GetTypeUrl())
+	}
+	gotData, err := got.GetData().UnmarshalNew()
+	if err != nil {
+		t.Fatalf("%s decode data: %v", what, err)
+	}
+	wantData, err := want.GetData().UnmarshalNew()
+	if err != nil {
+		t.Fatalf("%s decode sample data: %v", what, err)
+	}
+	if !proto.Equal(gotData, wantData) {
+		t.Errorf("%s data = %v, want %v", what, gotData, wantData)
+	}
+
+	got, want = proto.Clone(got).(*asset.Asset), proto.Clone(want).(*asset.Asset)
+	got.Data, want.Data = nil, nil
+	if !proto.Equal(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	want, encoded := encodings(t)
+	dir := t.TempDir()
+
+	for ext, b := range encoded {
+		ext, b := ext, b
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(dir, "asset"+ext)
+			if err := os.WriteFile(path, b, 0o600); err != nil {
+				t.Fatalf("os.WriteFile() error = %v", err)
+			}
+
+			got, err := assetio.ReadFile(path, assetio.FormatFromPath(path))
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			checkEqual(t, "ReadFile()", got, want)
+		})
+	}
+}
+
+func TestReadFileStdin(t *testing.T) {
+	want, encoded := encodings(t)
+
+	path := filepath.Join(t.TempDir(), "asset.yaml")
+	if err := os.WriteFile(path, encoded[".yaml"], 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("os.Open() error = %v", err)
+	}
+	defer f.Close()
+
+	stdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = stdin }()
+
+	got, err := assetio.ReadFile("-", assetio.FormatYAML)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	checkEqual(t, "ReadFile()", got, want)
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	for _, f := range assetio.Formats {
+		_, err := assetio.Unmarshal([]byte("{urn: [}"), f)
+		if errors.WhatKind(err) != errors.InvalidInput {
+			t.Errorf("Unmarshal(%s) error = %v, want kind %v", f, err, errors.InvalidInput)
+		}
+	}
+
+	if _, err := assetio.ParseFormat("xml"); errors.WhatKind(err) != errors.InvalidInput {
+		t.Errorf("ParseFormat() error = %v, want kind %v", err, errors.InvalidInput)
+	}
+}
@@ -0,0 +1,180 @@
+package assetio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// maxRecordSize is the maximum size of a single record in a stream.
+const maxRecordSize = 64 << 20
+
+// Scanner reads a stream of assets one record at a time. For FormatJSON,
+// each line holds one asset (NDJSON) and blank lines are skipped. For
+// FormatBinary, each asset is prefixed with its size encoded as a varint.
+//
+// Decoding a record is separate from scanning it so that a malformed
+// record does not stop the stream.
+type Scanner struct {
+	sc *bufio.Scanner
+	f  Format
+}
+
+// NewScanner returns a Scanner that reads from r. FormatYAML is not
+// supported for streams.
+func NewScanner(r io.Reader, f Format) (*Scanner, error) {
+	const op = "assetio.NewScanner"
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+
+	switch f {
+	case FormatJSON:
+		sc.Split(bufio.ScanLines)
+
+	case FormatBinary:
+		sc.Split(scanDelimited)
+
+	default:
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithTextf("unsupported stream format: %s", f))
+	}
+
+	return &Scanner{sc: sc, f: f}, nil
+}
+
+// Scan advances the Scanner to the next record. It returns false when the
+// scan stops, either by reaching the end of the input or an error.
+func (s *Scanner) Scan() bool {
+	for s.sc.Scan() {
+		if s.f == FormatJSON && len(s.sc.Bytes()) == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Bytes returns the encoded record generated by the most recent call to
+// Scan. The underlying array may be overwritten by a subsequent call.
+func (s *Scanner) Bytes() []byte { return s.sc.Bytes() }
+
+// Asset decodes the record generated by the most recent call to Scan.
+func (s *Scanner) Asset() (*asset.Asset, error) {
+	const op = "Scanner.Asset"
+
+	a, err := Unmarshal(s.sc.Bytes(), s.f)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return a, nil
+}
+
+// Err returns the first non-EOF error that was encountered by the Scanner.
+func (s *Scanner) Err() error {
+	const op = "Scanner.Err"
+
+	if err := s.sc.Err(); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+// scanDelimited is a bufio.SplitFunc that splits the input into records
+// prefixed with their varint encoded size.
+func scanDelimited(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	size, n := binary.Uvarint(data)
+	switch {
+	case n < 0:
+		return 0, nil, errors.E(errors.WithText("invalid record size prefix"))
+
+	case n == 0:
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil // Request more data.
+
+	case size > maxRecordSize:
+		return 0, nil, errors.E(errors.WithTextf("record size %d exceeds limit of %d", size, maxRecordSize))
+	}
+
+	end := n + int(size)
+	if len(data) < end {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil // Request more data.
+	}
+
+	return end, data[n:end], nil
+}
+
+// Encoder writes a stream of assets in the format read by Scanner.
+type Encoder struct {
+	w *bufio.Writer
+	f Format
+}
+
+// NewEncoder returns an Encoder that writes to w. FormatYAML is not
+// supported for streams.
+func NewEncoder(w io.Writer, f Format) (*Encoder, error) {
+	const op = "assetio.NewEncoder"
+
+	if f != FormatJSON && f != FormatBinary {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithTextf("unsupported stream format: %s", f))
+	}
+
+	return &Encoder{w: bufio.NewWriter(w), f: f}, nil
+}
+
+// Encode writes the asset as a single record to the stream.
+func (e *Encoder) Encode(a *asset.Asset) error {
+	const op = "Encoder.Encode"
+
+	var (
+		b   []byte
+		err error
+	)
+	switch e.f {
+	case FormatJSON:
+		b, err = protojson.Marshal(a)
+		b = append(b, '\n')
+
+	case FormatBinary:
+		var data []byte
+		data, err = proto.Marshal(a)
+		b = binary.AppendUvarint(make([]byte, 0, len(data)+binary.MaxVarintLen64), uint64(len(data)))
+		b = append(b, data...)
+	}
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if _, err := e.w.Write(b); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+// Flush writes any buffered records to the underlying io.Writer.
+func (e *Encoder) Flush() error {
+	const op = "Encoder.Flush"
+
+	if err := e.w.Flush(); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package assetio_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func TestStream(t *testing.T) {
+	want, _ := encodings(t)
+
+	for _, f := range []assetio.Format{assetio.FormatJSON, assetio.FormatBinary} {
+		f := f
+		t.Run(string(f), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := assetio.NewEncoder(&buf, f)
+			if err != nil {
+				t.Fatalf("NewEncoder() error = %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				a := proto.Clone(want).(*asset.Asset)
+				a.Urn = fmt.Sprint(i)
+				if err := enc.Encode(a); err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+			}
+			if err := enc.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+
+			sc, err := assetio.NewScanner(&buf, f)
+			if err != nil {
+				t.Fatalf("NewScanner() error = %v", err)
+			}
+			var n int
+			for ; sc.Scan(); n++ {
+				got, err := sc.Asset()
+				if err != nil {
+					t.Fatalf("Asset() error = %v", err)
+				}
+				w := proto.Clone(want).(*asset.Asset)
+				w.Urn = fmt.Sprint(n)
+				checkEqual(t, fmt.Sprintf("record %d", n), got, w)
+			}
+			if err := sc.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			if n != 3 {
+				t.Errorf("scanned %d records, want 3", n)
+			}
+		})
+	}
+}
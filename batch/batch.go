@@ -0,0 +1,176 @@
+// Package batch runs a Transformer over a stream of assets.
+package batch
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Failure is a record that could not be decoded or transformed.
+type Failure struct {
+	// Index is the zero-based position of the record in the stream.
+	Index int
+	// URN of the asset, empty if the record could not be decoded.
+	URN string
+	Err error
+}
+
+// Summary of a batch run.
+type Summary struct {
+	Total    int
+	Failures []Failure
+	// Dropped is the number of records for which the script produced no
+	// asset.
+	Dropped int
+	// Written is the number of assets flushed to the writer of the
+	// encoder, which differs from the number of records that succeeded
+	// if the script drops records or fans them out into multiple assets.
+	Written int
+}
+
+// Succeeded is the number of records that were transformed, including
+// those dropped by the script.
+func (s Summary) Succeeded() int { return s.Total - len(s.Failures) }
+
+// Run transforms each asset read by the scanner and writes the results to
+// the encoder, preserving the order of the input. The assets produced from
+// a record are those returned by engine.Expand, so that a record can be
+// dropped or fanned out by the script. Up to workers assets are
+// transformed concurrently. A record that cannot be decoded or transformed
+// is skipped and reported in the Summary without aborting the batch. The
+// returned error is non-nil only if the stream itself cannot be read or
+// written, or if the context is done.
+func Run(ctx context.Context, t engine.Transformer, sc *assetio.Scanner, enc *assetio.Encoder, workers int) (Summary, error) {
+	const op = "batch.Run"
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each record is sent to the workers and, in the same order, to the
+	// writer which waits for the record to be done before writing it.
+	var (
+		jobs    = make(chan *job)
+		ordered = make(chan *job, workers)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if j.err == nil {
+					j.res, j.err = engine.Expand(ctx, t, j.a)
+				}
+				close(j.done)
+			}
+		}()
+	}
+
+	var scanErr error
+	scanDone := make(chan struct{})
+	go func() {
+		defer close(scanDone)
+		defer close(ordered)
+		defer close(jobs)
+
+		for i := 0; sc.Scan(); i++ {
+			j := job{index: i, done: make(chan struct{})}
+			j.a, j.err = sc.Asset()
+
+			select {
+			case ordered <- &j:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- &j:
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr = sc.Err()
+	}()
+
+	s, err := write(ctx, ordered, enc)
+	cancel()
+	wg.Wait()
+	<-scanDone
+
+	if err != nil {
+		return s, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	if scanErr != nil {
+		return s, errors.E(errors.WithOp(op), errors.WithTextf("read record %d", s.Total), errors.WithErr(scanErr))
+	}
+
+	return s, nil
+}
+
+type job struct {
+	index int
+	a     *asset.Asset
+	// res holds the assets produced from a.
+	res []*asset.Asset
+	// err is the error from decoding or transforming the asset.
+	err  error
+	done chan struct{}
+}
+
+// write writes the assets in the order the jobs are received and returns
+// the summary. It returns early if the context is done. The encoder is
+// flushed however it returns, and the assets are only counted as written
+// once it has been.
+func write(ctx context.Context, ordered <-chan *job, enc *assetio.Encoder) (s Summary, err error) {
+	var encoded int
+	defer func() {
+		ferr := enc.Flush()
+		switch {
+		case ferr == nil:
+			s.Written += encoded
+		case err == nil:
+			err = ferr
+		default:
+			err = errors.E(errors.WithTextf("flush failed (%s) after error", ferr), errors.WithErr(err))
+		}
+	}()
+
+	for j := range ordered {
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			return s, ctx.Err()
+		}
+
+		s.Total++
+		if j.err != nil {
+			f := Failure{Index: j.index, Err: j.err}
+			if j.a != nil {
+				f.URN = j.a.Urn
+			}
+			s.Failures = append(s.Failures, f)
+			continue
+		}
+
+		if len(j.res) == 0 {
+			s.Dropped++
+		}
+		for _, a := range j.res {
+			if err := enc.Encode(a); err != nil {
+				return s, err
+			}
+			encoded++
+		}
+	}
+
+	return s, ctx.Err()
+}
@@ -0,0 +1,291 @@
+package batch_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	_ "github.com/sudo-suhas/play-script-engine/anko"
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/batch"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	_ "github.com/sudo-suhas/play-script-engine/golua"
+	_ "github.com/sudo-suhas/play-script-engine/gopherlua"
+	_ "github.com/sudo-suhas/play-script-engine/otto"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+	_ "github.com/sudo-suhas/play-script-engine/tengo"
+)
+
+// transformerFunc is an engine.Transformer which calls the function.
+type transformerFunc func(ctx context.Context, a *asset.Asset) error
+
+func (f transformerFunc) T(ctx context.Context, a *asset.Asset) error { return f(ctx, a) }
+
+func TestRun(t *testing.T) {
+	const n = 20
+
+	var in bytes.Buffer
+	for i := 0; i < n; i++ {
+		if i == 5 {
+			in.WriteString("{not json\n")
+			continue
+		}
+		b, err := protojson.Marshal(&asset.Asset{Urn: fmt.Sprintf("urn:%d", i)})
+		if err != nil {
+			t.Fatalf("protojson.Marshal() error = %v", err)
+		}
+		in.Write(append(b, '\n'))
+	}
+
+	// The earlier records take longer, so that they finish out of order.
+	tr := transformerFunc(func(_ context.Context, a *asset.Asset) error {
+		var i int
+		if _, err := fmt.Sscanf(a.Urn, "urn:%d", &i); err != nil {
+			return err
+		}
+		time.Sleep(time.Duration(n-i) * time.Millisecond)
+		if i == 11 {
+			return errors.E(errors.WithText("script failed"))
+		}
+		a.Name = a.Urn
+		return nil
+	})
+
+	sc, err := assetio.NewScanner(&in, assetio.FormatJSON)
+	if err != nil {
+		t.Fatalf("NewScanner() error = %v", err)
+	}
+	var out bytes.Buffer
+	enc, err := assetio.NewEncoder(&out, assetio.FormatJSON)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	s, err := batch.Run(context.Background(), tr, sc, enc, 4)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if s.Total != n || s.Succeeded() != n-2 || s.Written != n-2 || s.Dropped != 0 {
+		t.Errorf("Run() summary = %+v, want %d records of which 2 failed", s, n)
+	}
+	if len(s.Failures) != 2 {
+		t.Fatalf("Run() failures = %v, want 2", s.Failures)
+	}
+	if f := s.Failures[0]; f.Index != 5 || f.URN != "" || errors.WhatKind(f.Err) != errors.InvalidInput {
+		t.Errorf("Run() failures[0] = %+v, want the malformed record 5", f)
+	}
+	if f := s.Failures[1]; f.Index != 11 || f.URN != "urn:11" || f.Err == nil {
+		t.Errorf("Run() failures[1] = %+v, want the failed transform of record 11", f)
+	}
+
+	var urns []string
+	for _, a := range decode(t, out.Bytes()) {
+		if a.Name != a.Urn {
+			t.Errorf("Run() wrote %v, want the transformed asset", a)
+		}
+		urns = append(urns, a.Urn)
+	}
+	var want []string
+	for i := 0; i < n; i++ {
+		if i != 5 && i != 11 {
+			want = append(want, fmt.Sprintf("urn:%d", i))
+		}
+	}
+	if got := strings.Join(urns, ","); got != strings.Join(want, ",") {
+		t.Errorf("Run() wrote assets %s, want %s", got, strings.Join(want, ","))
+	}
+}
+
+// TestRunCancel cancels the batch while it runs, which must still write the
+// assets encoded before, and count only them as written.
+func TestRunCancel(t *testing.T) {
+	const n = 20
+
+	var in bytes.Buffer
+	for i := 0; i < n; i++ {
+		b, err := protojson.Marshal(&asset.Asset{Urn: fmt.Sprintf("urn:%d", i)})
+		if err != nil {
+			t.Fatalf("protojson.Marshal() error = %v", err)
+		}
+		in.Write(append(b, '\n'))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := transformerFunc(func(ctx context.Context, a *asset.Asset) error {
+		if a.Urn == "urn:5" {
+			cancel()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	sc, err := assetio.NewScanner(&in, assetio.FormatJSON)
+	if err != nil {
+		t.Fatalf("NewScanner() error = %v", err)
+	}
+	var out bytes.Buffer
+	enc, err := assetio.NewEncoder(&out, assetio.FormatJSON)
+	if err != nil {
+		t.Fatalf("NewEncoder() error = %v", err)
+	}
+
+	// With a single worker, the writer has taken record 4 by the time
+	// record 5 is transformed, so records 0 to 3 have been encoded.
+	s, err := batch.Run(ctx, tr, sc, enc, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want %v", err, context.Canceled)
+	}
+
+	got := decode(t, out.Bytes())
+	if s.Written < 4 || len(got) != s.Written {
+		t.Errorf("Run() wrote %d assets and counted %d, want the same number of at least 4", len(got), s.Written)
+	}
+	for i, a := range got {
+		// Record 5 fails, the records after it may still be written.
+		if i >= 5 {
+			i++
+		}
+		if want := fmt.Sprintf("urn:%d", i); a.Urn != want {
+			t.Errorf("Run() wrote %s at %d, want %s", a.Urn, i, want)
+		}
+	}
+}
+
+// printScripts print from the script, by engine. goja, gojq and bloblang
+// have nothing to print with.
+var printScripts = map[string]engine.ScriptString{
+	"anko":      `println("hello from anko")`,
+	"golua":     `print("hello", "from", "golua")`,
+	"gopherlua": `print("hello", "from", "gopherlua")`,
+	"otto":      `console.log("hello from otto"); console.error("and again")`,
+	"tengo":     `fmt := import("fmt"); fmt.println("hello from tengo"); fmt.printf("%s\n", "and again")`,
+}
+
+// TestRunPrint checks that printing from the script does not corrupt the
+// stream of assets written to stdout.
+func TestRunPrint(t *testing.T) {
+	for name, script := range printScripts {
+		name, script := name, script
+		t.Run(name, func(t *testing.T) {
+			tr, err := engine.New(name, engine.Options{Script: script, URLer: func(s string) string { return s }})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			var in bytes.Buffer
+			for i := 0; i < 3; i++ {
+				a, err := sample.FeatureTable()
+				if err != nil {
+					t.Fatalf("sample.FeatureTable() error = %v", err)
+				}
+				a.Urn = fmt.Sprintf("urn:%d", i)
+				b, err := protojson.Marshal(a)
+				if err != nil {
+					t.Fatalf("protojson.Marshal() error = %v", err)
+				}
+				in.Write(append(b, '\n'))
+			}
+
+			var s batch.Summary
+			out := captureStdout(t, func() {
+				sc, err := assetio.NewScanner(&in, assetio.FormatJSON)
+				if err != nil {
+					t.Fatalf("NewScanner() error = %v", err)
+				}
+				enc, err := assetio.NewEncoder(os.Stdout, assetio.FormatJSON)
+				if err != nil {
+					t.Fatalf("NewEncoder() error = %v", err)
+				}
+				if s, err = batch.Run(context.Background(), tr, sc, enc, 2); err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			})
+
+			if len(s.Failures) != 0 {
+				t.Fatalf("Run() failures = %v", s.Failures)
+			}
+			if got := len(decode(t, out)); got != 3 {
+				t.Errorf("Run() wrote %d assets, want 3", got)
+			}
+		})
+	}
+}
+
+// TestPrintOutput checks that the script prints to Options.Output if it is
+// set.
+func TestPrintOutput(t *testing.T) {
+	for name, script := range printScripts {
+		name, script := name, script
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			tr, err := engine.New(name, engine.Options{Script: script, URLer: func(s string) string { return s }, Output: &out})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+			if err := tr.T(context.Background(), a); err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+
+			if got := out.String(); !strings.HasPrefix(got, "hello") || !strings.Contains(got, name) {
+				t.Errorf("T() printed %q, want the line printed by the script", got)
+			}
+		})
+	}
+}
+
+// captureStdout returns what is written to os.Stdout while f runs.
+func captureStdout(t *testing.T, f func()) []byte {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	read := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		read <- b
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+
+	return <-read
+}
+
+// decode decodes each line of the NDJSON stream.
+func decode(t *testing.T, b []byte) []*asset.Asset {
+	t.Helper()
+
+	var assets []*asset.Asset
+	for i, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		a, err := assetio.Unmarshal([]byte(line), assetio.FormatJSON)
+		if err != nil {
+			t.Fatalf("line %d: %q is not an asset: %v", i, line, err)
+		}
+		assets = append(assets, a)
+	}
+	return assets
+}
@@ -0,0 +1,254 @@
+// Package bench builds the cases with which the performance of the script
+// engines is measured, by the Go benchmarks of the package and by Measure
+// for the bench subcommand.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// DefaultSizes are the numbers of features in the feature tables used by
+// Sizes if none are given.
+var DefaultSizes = []int{10, 1000, 50000}
+
+// Case is a benchmark of one engine. Each op calls Fn with a clone of one
+// of the assets, taken in turn. The clone is not included in the
+// measurements.
+type Case struct {
+	Engine string
+	Name   string
+	Assets []*asset.Asset
+	Fn     func(*asset.Asset) error
+}
+
+// Result of a benchmark.
+type Result struct {
+	Engine string `json:"engine"`
+	Case   string `json:"case"`
+	// Features is the mean number of features in the feature tables
+	// transformed.
+	Features    int   `json:"features"`
+	N           int   `json:"n"`
+	NsPerOp     int64 `json:"ns_per_op"`
+	AllocsPerOp int64 `json:"allocs_per_op"`
+	BytesPerOp  int64 `json:"bytes_per_op"`
+	// P50Ns and P99Ns are percentiles of the latency of a single op in
+	// nanoseconds.
+	P50Ns int64 `json:"p50_ns"`
+	P99Ns int64 `json:"p99_ns"`
+}
+
+// Compile returns the cases comparing transforming the asset with a
+// Transformer that is built once against building a new Transformer, and
+// so compiling the script, for each call.
+func Compile(ctx context.Context, name string, opts engine.Options, a *asset.Asset) ([]Case, error) {
+	const op = "bench.Compile"
+
+	t, err := engine.New(name, opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	// Fail early, before running the benchmarks, if the script fails.
+	if err := t.T(ctx, proto.Clone(a).(*asset.Asset)); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	compiledOnce := Case{
+		Engine: name,
+		Name:   "compiled_once",
+		Assets: []*asset.Asset{a},
+		Fn:     func(in *asset.Asset) error { return t.T(ctx, in) },
+	}
+	compiledPerCall := Case{
+		Engine: name,
+		Name:   "compiled_per_call",
+		Assets: []*asset.Asset{a},
+		Fn: func(in *asset.Asset) error {
+			t, err := engine.New(name, opts)
+			if err != nil {
+				return err
+			}
+			return t.T(ctx, in)
+		},
+	}
+
+	return []Case{compiledOnce, compiledPerCall}, nil
+}
+
+// Sizes returns the cases transforming feature tables with each of the
+// given numbers of features, or DefaultSizes if none are given, with a
+// Transformer that is built once.
+func Sizes(ctx context.Context, name string, opts engine.Options, sizes ...int) ([]Case, error) {
+	const op = "bench.Sizes"
+
+	if len(sizes) == 0 {
+		sizes = DefaultSizes
+	}
+
+	t, err := engine.New(name, opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	cases := make([]Case, 0, len(sizes))
+	for _, n := range sizes {
+		a, err := sample.FeatureTableWithFeatures(n)
+		if err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+
+		if err := t.T(ctx, proto.Clone(a).(*asset.Asset)); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithTextf("%d features", n), errors.WithErr(err))
+		}
+
+		cases = append(cases, Case{
+			Engine: name,
+			Name:   fmt.Sprintf("features_%d", n),
+			Assets: []*asset.Asset{a},
+			Fn:     func(in *asset.Asset) error { return t.T(ctx, in) },
+		})
+	}
+
+	return cases, nil
+}
+
+// Generated returns the case transforming n assets built by
+// sample.Generator, in turn, with a Transformer that is built once. The
+// assets vary in shape, such as in the number of features and whether the
+// lineage is nil.
+func Generated(ctx context.Context, name string, opts engine.Options, n int) (Case, error) {
+	const op = "bench.Generated"
+
+	t, err := engine.New(name, opts)
+	if err != nil {
+		return Case{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	assets, err := sample.NewGenerator(1, sample.GenOptions{}).Assets(n)
+	if err != nil {
+		return Case{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	for i, a := range assets {
+		if err := t.T(ctx, proto.Clone(a).(*asset.Asset)); err != nil {
+			return Case{}, errors.E(errors.WithOp(op), errors.WithTextf("asset %d", i), errors.WithErr(err))
+		}
+	}
+
+	return Case{
+		Engine: name,
+		Name:   "generated",
+		Assets: assets,
+		Fn:     func(in *asset.Asset) error { return t.T(ctx, in) },
+	}, nil
+}
+
+// Measure runs the case for about d, at least once, and returns its
+// measurements. The ops are run in batches, sized so that a batch takes
+// about a tenth of d, for the inputs of which the assets are cloned up
+// front.
+func Measure(c Case, d time.Duration) (Result, error) {
+	const op = "bench.Measure"
+
+	var (
+		latencies     []time.Duration
+		elapsed       time.Duration
+		allocs, bytes uint64
+		before, after runtime.MemStats
+	)
+	for batch := 1; elapsed < d; {
+		inputs := make([]*asset.Asset, batch)
+		for i := range inputs {
+			inputs[i] = proto.Clone(c.Assets[(len(latencies)+i)%len(c.Assets)]).(*asset.Asset)
+		}
+
+		runtime.ReadMemStats(&before)
+		for _, in := range inputs {
+			start := time.Now()
+			if err := c.Fn(in); err != nil {
+				return Result{}, errors.E(errors.WithOp(op), errors.WithTextf("%s %s", c.Engine, c.Name), errors.WithErr(err))
+			}
+			l := time.Since(start)
+			latencies = append(latencies, l)
+			elapsed += l
+		}
+		runtime.ReadMemStats(&after)
+		allocs += after.Mallocs - before.Mallocs
+		bytes += after.TotalAlloc - before.TotalAlloc
+
+		// Aim for a tenth of d per batch, growing by at most 100x.
+		perOp := elapsed / time.Duration(len(latencies))
+		if perOp <= 0 {
+			perOp = 1
+		}
+		next := int(d / 10 / perOp)
+		if next > 100*batch {
+			next = 100 * batch
+		}
+		if next < 1 {
+			next = 1
+		}
+		batch = next
+	}
+
+	n := len(latencies)
+	p50, p99 := Percentiles(latencies)
+	return Result{
+		Engine:      c.Engine,
+		Case:        c.Name,
+		Features:    features(c.Assets),
+		N:           n,
+		NsPerOp:     elapsed.Nanoseconds() / int64(n),
+		AllocsPerOp: int64(allocs / uint64(n)),
+		BytesPerOp:  int64(bytes / uint64(n)),
+		P50Ns:       p50.Nanoseconds(),
+		P99Ns:       p99.Nanoseconds(),
+	}, nil
+}
+
+// Percentiles sorts the latencies and returns their 50th and 99th
+// percentiles, by the nearest rank.
+func Percentiles(latencies []time.Duration) (p50, p99 time.Duration) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return percentile(latencies, 0.50), percentile(latencies, 0.99)
+}
+
+// percentile returns the p-th percentile, by the nearest rank, of the
+// sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// features returns the mean number of features in the feature tables.
+func features(assets []*asset.Asset) int {
+	var total int
+	for _, a := range assets {
+		var data asset.FeatureTable
+		if err := a.GetData().UnmarshalTo(&data); err != nil {
+			continue
+		}
+		total += len(data.Features)
+	}
+	return total / len(assets)
+}
@@ -0,0 +1,97 @@
+package bench_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	_ "github.com/sudo-suhas/play-script-engine/anko"
+	"github.com/sudo-suhas/play-script-engine/bench"
+	_ "github.com/sudo-suhas/play-script-engine/bloblang"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	_ "github.com/sudo-suhas/play-script-engine/goja"
+	_ "github.com/sudo-suhas/play-script-engine/gojq"
+	_ "github.com/sudo-suhas/play-script-engine/golua"
+	_ "github.com/sudo-suhas/play-script-engine/gopherlua"
+	_ "github.com/sudo-suhas/play-script-engine/otto"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+	_ "github.com/sudo-suhas/play-script-engine/tengo"
+)
+
+var opts = engine.Options{URLer: func(name string) string { return "https://example.com/" + name }}
+
+func BenchmarkCompile(b *testing.B) {
+	a, err := sample.FeatureTable()
+	if err != nil {
+		b.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	for _, name := range engine.Names() {
+		cases, err := bench.Compile(context.Background(), name, opts, a)
+		if err != nil {
+			b.Fatalf("Compile() error = %v", err)
+		}
+		run(b, cases)
+	}
+}
+
+func BenchmarkSizes(b *testing.B) {
+	for _, name := range engine.Names() {
+		cases, err := bench.Sizes(context.Background(), name, opts)
+		if err != nil {
+			b.Fatalf("Sizes() error = %v", err)
+		}
+		run(b, cases)
+	}
+}
+
+func BenchmarkGenerated(b *testing.B) {
+	for _, name := range engine.Names() {
+		c, err := bench.Generated(context.Background(), name, opts, 100)
+		if err != nil {
+			b.Fatalf("Generated() error = %v", err)
+		}
+		run(b, []bench.Case{c})
+	}
+}
+
+func BenchmarkStructmap(b *testing.B) {
+	a, err := sample.FeatureTable()
+	if err != nil {
+		b.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	run(b, bench.Structmap(a))
+}
+
+// run runs a sub-benchmark for each case, which also reports the p50 and
+// p99 latency of a single op.
+func run(b *testing.B, cases []bench.Case) {
+	for _, c := range cases {
+		c := c
+		b.Run(c.Engine+"/"+c.Name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			latencies := make([]time.Duration, 0, b.N)
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				in := proto.Clone(c.Assets[i%len(c.Assets)]).(*asset.Asset)
+				b.StartTimer()
+
+				start := time.Now()
+				if err := c.Fn(in); err != nil {
+					b.Fatal(err)
+				}
+				latencies = append(latencies, time.Since(start))
+			}
+			b.StopTimer()
+
+			p50, p99 := bench.Percentiles(latencies)
+			b.ReportMetric(float64(p50.Nanoseconds()), "p50-ns")
+			b.ReportMetric(float64(p99.Nanoseconds()), "p99-ns")
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/structmap"
+)
+
+// Structmap returns the cases comparing converting the asset to a map and
+// back with the protoreflect based structmap against a round trip through
+// encoding/json, which structmap used to do.
+func Structmap(a *asset.Asset) []Case {
+	protoreflect := Case{
+		Engine: "structmap",
+		Name:   "protoreflect",
+		Assets: []*asset.Asset{a},
+		Fn: func(in *asset.Asset) error {
+			w, err := structmap.NewAssetWrapper(in)
+			if err != nil {
+				return err
+			}
+
+			m, err := w.Encode(structmap.Format{Time: structmap.TimeRFC3339})
+			if err != nil {
+				return err
+			}
+
+			return w.OverwriteWith(m)
+		},
+	}
+	jsonRoundTrip := Case{
+		Engine: "structmap",
+		Name:   "json_round_trip",
+		Assets: []*asset.Asset{a},
+		Fn:     jsonRoundTrip,
+	}
+
+	return []Case{protoreflect, jsonRoundTrip}
+}
+
+// jsonRoundTrip converts the asset and its unpacked data to maps with
+// encoding/json and back again.
+func jsonRoundTrip(a *asset.Asset) error {
+	data, err := a.Data.UnmarshalNew()
+	if err != nil {
+		return err
+	}
+
+	var m, dm map[string]interface{}
+	if err := convertJSON(a, &m); err != nil {
+		return err
+	}
+	if err := convertJSON(data, &dm); err != nil {
+		return err
+	}
+
+	if err := convertJSON(dm, data); err != nil {
+		return err
+	}
+	delete(m, "data")
+	if err := convertJSON(m, a); err != nil {
+		return err
+	}
+
+	a.Data, err = anypb.New(data)
+	return err
+}
+
+func convertJSON(in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, out)
+}
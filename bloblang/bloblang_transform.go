@@ -2,50 +2,57 @@ package bloblang
 
 import (
 	"context"
+	_ "embed"
+	"runtime"
 
 	"github.com/benthosdev/benthos/v4/public/bloblang"
 	"github.com/sudo-suhas/xgo/errors"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
 	"github.com/sudo-suhas/play-script-engine/structmap"
 )
 
-var mapping = `
-asset.labels.script_engine = "bloblang"
+//go:embed default.blobl
+var defaultMapping string
 
-map entity_name {
-	root = this
-	root.entity_name = match this.name {
-		"ongoing_placed_and_waiting_acceptance_orders" => "customer_orders",
-		"ongoing_orders" => "customer_orders",
-		"merchant_avg_dispatch_arrival_time_10m" => "merchant_driver",
-		"ongoing_accepted_orders" => "merchant_orders",
-	}
+func init() {
+	engine.Register("bloblang", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
 }
-asset.data.features = asset.data.features.map_each(f -> f.apply("entity_name"))
-
-asset.owners = asset.owners.or([]).append({ "name": "Big Mom", "email": "big.mom@wholecakeisland.com" })
-
-asset.url = urler(asset.name)
 
-map urn_replace {
-	root = this
-	root.urn = this.urn.replace_all(".yonkou.io", "")
+// Transformer is safe for concurrent use. The executor does not hold any
+// state between executions of the mapping.
+//
+// A mapping cannot be interrupted. If the context is done, T returns
+// without waiting for the mapping, which runs to completion in the
+// background and its result is discarded. To bound the goroutines left
+// running by such mappings, at most GOMAXPROCS mappings run at once,
+// including those whose T has returned. T waits for one of them to
+// finish, or for the context to be done, before running the mapping.
+type Transformer struct {
+	exe    *bloblang.Executor
+	limits engine.Limits
+	// slots holds a value for each mapping that is running.
+	slots chan struct{}
 }
 
-asset.lineage.upstreams = asset.lineage.upstreams.map_each(u -> if u.service == "kafka" {
-	u.apply("urn_replace")
-} else {
-	u
-})
-`
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "bloblang.New"
 
-type Transformer struct {
-	URLer func(string) string
-}
+	if err := opts.Limits.Supported("bloblang"); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-func (t *Transformer) T(_ context.Context, a *asset.Asset) error {
-	const op = "bloblang.Transform"
+	mapping, err := opts.ScriptSource(defaultMapping)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
 	env := bloblang.NewEnvironment().
 		WithDisabledImports().
@@ -60,15 +67,33 @@ func (t *Transformer) T(_ context.Context, a *asset.Asset) error {
 		}
 
 		return func() (any, error) {
-			return t.URLer(name), nil
+			return opts.URLer(name), nil
 		}, nil
 	}); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("register function"), errors.WithErr(err))
+		return nil, errors.E(errors.WithOp(op), errors.WithText("register function"), errors.WithErr(err))
 	}
 
 	exe, err := env.Parse(mapping)
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("parse mapping"), errors.WithErr(err))
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("parse mapping"), errors.WithErr(err))
+	}
+
+	return &Transformer{
+		exe:    exe,
+		limits: opts.Limits,
+		slots:  make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}, nil
+}
+
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
+	const op = "bloblang.Transform"
+
+	defer engine.Recover(op, "bloblang", a.GetUrn(), &err)
+
+	a, commit := engine.Stage(a)
+
+	if err := engine.ContextError(ctx); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
 	wrapper, err := structmap.NewAssetWrapper(a)
@@ -76,15 +101,60 @@ func (t *Transformer) T(_ context.Context, a *asset.Asset) error {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	m := wrapper.Encode()
-	var v interface{} = map[string]interface{}{"asset": m}
-	if err := exe.Overlay(v, &v); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("execute mapping"), errors.WithErr(err))
+	m, err := wrapper.Encode(structmap.Format{Time: structmap.TimeRFC3339})
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	if err := wrapper.OverwriteWith(m); err != nil {
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return errors.E(errors.WithOp(op), errors.WithErr(engine.ContextError(ctx)))
+	}
+
+	// The panics in the goroutine running the mapping are not recovered by
+	// the deferred call above. The slot is released once the mapping has
+	// finished, even if T has returned.
+	// The output of the mapping is read once it is received from done.
+	urn := a.GetUrn()
+	done := make(chan error, 1)
+	var out interface{}
+	go func() {
+		var err error
+		defer func() { <-t.slots }()
+		defer func() { done <- err }()
+		defer engine.Recover(op, "bloblang", urn, &err)
+
+		var v interface{} = map[string]interface{}{"asset": m}
+		err = t.exe.Overlay(v, &v)
+		out = v
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.WithText("execute mapping"), errors.WithErr(err))
+		}
+
+	case <-ctx.Done():
+		return errors.E(errors.WithOp(op), errors.WithErr(engine.ContextError(ctx)))
+	}
+
+	root, _ := out.(map[string]interface{})
+	res, ok := root["asset"].(map[string]interface{})
+	if !ok {
+		return errors.E(errors.WithOp(op), errors.WithTextf("unexpected result: asset is %T", root["asset"]))
+	}
+
+	if err := wrapper.OverwriteWith(res); err != nil {
 		return errors.E(errors.WithOp(op), errors.WithText("decode map"), errors.WithErr(err))
 	}
 
+	if err := t.limits.CheckOutput(a); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	commit()
+
 	return nil
 }
@@ -0,0 +1,100 @@
+package bloblang_test
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/bloblang"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// TestTransformerStuck runs a mapping which is stuck in urler until the
+// test releases it. The mappings left running once T returns are bounded
+// by GOMAXPROCS.
+func TestTransformerStuck(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		started int32
+	)
+	tr, err := bloblang.New(engine.Options{
+		Script: engine.ScriptString(`asset.url = urler(asset.name)`),
+		URLer: func(s string) string {
+			atomic.AddInt32(&started, 1)
+			<-release
+			return s
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	n := runtime.GOMAXPROCS(0)
+	for i := 0; i < 2*n; i++ {
+		a, err := sample.FeatureTable()
+		if err != nil {
+			t.Fatalf("sample.FeatureTable() error = %v", err)
+		}
+		want := proto.Clone(a)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err = tr.T(ctx, a)
+		cancel()
+
+		if errors.WhatKind(err) != errors.DeadlineExceeded {
+			t.Errorf("run %d: T() error = %v, want kind %v", i, err, errors.DeadlineExceeded)
+		}
+		if !proto.Equal(a, want) {
+			t.Errorf("run %d: T() changed the asset to %v", i, a)
+		}
+	}
+
+	if got := atomic.LoadInt32(&started); got != int32(n) {
+		t.Errorf("mappings started = %d, want %d", got, n)
+	}
+
+	// Once the stuck mappings finish, the next one can run.
+	close(release)
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+	if a.Url != a.Name {
+		t.Errorf("url = %q, want %q", a.Url, a.Name)
+	}
+}
+
+// TestTransformerReplaceAsset runs a mapping which replaces the asset as a
+// whole, which is only seen in the output of the mapping.
+func TestTransformerReplaceAsset(t *testing.T) {
+	tr, err := bloblang.New(engine.Options{
+		Script: engine.ScriptString(`asset = this.asset.without("labels").assign({"name": "replaced"})`),
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+	a.Labels = map[string]string{"team": "logistics"}
+
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	if a.Name != "replaced" || len(a.Labels) != 0 {
+		t.Errorf("T() = %v, want the name replaced and no labels", a)
+	}
+}
@@ -0,0 +1,97 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// failScripts change the asset and its data and then fail, by engine.
+var failScripts = map[string]engine.ScriptString{
+	"anko": `
+asset.name = "mutated"
+asset.data.namespace = "mutated"
+throw "fail"
+`,
+	"bloblang": `
+asset.name = "mutated"
+asset.data.namespace = "mutated"
+asset.description = throw("fail")
+`,
+	"goja": `
+asset.name = "mutated";
+asset.data.namespace = "mutated";
+throw new Error("fail");
+`,
+	"gojq": `.name = "mutated" | .data.namespace = "mutated" | error("fail")`,
+	"golua": `
+asset.name = "mutated"
+asset.data.namespace = "mutated"
+error("fail")
+`,
+	"gopherlua": `
+asset.name = "mutated"
+asset.data.namespace = "mutated"
+error("fail")
+`,
+	"otto": `
+asset.name = "mutated";
+asset.data.namespace = "mutated";
+throw new Error("fail");
+`,
+	"tengo": `
+asset.name = "mutated"
+asset.data.namespace = "mutated"
+zero := 0
+asset.description = 1 / zero
+`,
+}
+
+// atomic runs a script which changes the asset and then fails over the
+// input of every case. T must return the error and leave the asset as it
+// was, down to the bytes of its data.
+func atomic(ctx context.Context, name string, cases []Case, opts engine.Options) ([]Result, error) {
+	const op = "conformance.atomic"
+
+	script, ok := failScripts[name]
+	if !ok {
+		return nil, errors.E(errors.WithOp(op), errors.WithTextf("no failing script for engine %s", name))
+	}
+	opts.Script = script
+	t, err := engine.New(name, opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	results := make([]Result, len(cases))
+	for i, c := range cases {
+		got := proto.Clone(c.Input).(*asset.Asset)
+		res := Result{Engine: name, Case: c.Name, Requirement: "atomic"}
+		if err := t.T(ctx, got); err == nil {
+			res.Err = errors.E(errors.WithText("transform: got no error"))
+		} else {
+			res.Err = checkIdentical(got, c.Input)
+		}
+		results[i] = res
+	}
+
+	return results, nil
+}
+
+// checkIdentical compares the assets and the bytes of their data, which
+// are kept as they are by an asset left untouched.
+func checkIdentical(got, want *asset.Asset) error {
+	if !proto.Equal(got, want) {
+		return errors.E(errors.WithTextf("got {%v}, want {%v}", got, want))
+	}
+	if !bytes.Equal(got.GetData().GetValue(), want.GetData().GetValue()) {
+		return errors.E(errors.WithText("data: the bytes changed"))
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package conformance_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// leakScripts set the description of the asset to "clean" unless they see
+// the changes made by an earlier run to the globals or the built-ins, by
+// engine. The engines which work on maps have no state to leak.
+var leakScripts = map[string]engine.ScriptString{
+	"anko": `
+try {
+	leak
+	asset.description = "leaked"
+} catch {
+	asset.description = "clean"
+}
+leak = 1
+`,
+	"bloblang": `asset.description = "clean"`,
+	"goja": `
+asset.description = typeof leak === "undefined" && typeof Object.prototype.leak === "undefined" &&
+	typeof Math.leak === "undefined" ? "clean" : "leaked";
+leak = 1;
+Object.prototype.leak = 1;
+Math.leak = 1;
+`,
+	"gojq": `.description = "clean"`,
+	"golua": `
+asset.description = (leak == nil and string.leak == nil and math.leak == nil) and "clean" or "leaked"
+leak = 1
+string.leak = 1
+math.leak = 1
+`,
+	"gopherlua": `
+asset.description = (leak == nil and string.leak == nil and math.leak == nil) and "clean" or "leaked"
+leak = 1
+string.leak = 1
+math.leak = 1
+`,
+	"otto": `
+asset.description = typeof leak === "undefined" && typeof Object.prototype.leak === "undefined" &&
+	typeof Math.leak === "undefined" && typeof _.leak === "undefined" ? "clean" : "leaked";
+leak = 1;
+Object.prototype.leak = 1;
+Math.leak = 1;
+_.leak = 1;
+`,
+	"tengo": `
+runs := 0
+runs += 1
+asset.description = runs == 1 ? "clean" : "leaked"
+`,
+}
+
+// TestConcurrent runs the Transformer of every engine from several
+// goroutines, which is checked for races with -race, and checks that no
+// run sees the state left by another.
+func TestConcurrent(t *testing.T) {
+	const (
+		goroutines = 8
+		runs       = 20
+	)
+
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			script, ok := leakScripts[name]
+			if !ok {
+				t.Fatalf("no leak script for engine %s", name)
+			}
+			tr, err := engine.New(name, engine.Options{Script: script, URLer: func(s string) string { return s }})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			in, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for i := 0; i < runs; i++ {
+						a := proto.Clone(in).(*asset.Asset)
+						if err := tr.T(context.Background(), a); err != nil {
+							t.Errorf("T() error = %v", err)
+							return
+						}
+						if a.Description != "clean" {
+							t.Errorf("T() description = %q, want clean", a.Description)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
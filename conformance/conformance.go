@@ -0,0 +1,214 @@
+// Package conformance checks the script engines against the requirements
+// documented on engine.Transformer.
+//
+// Each case is a pair of assets in testdata: the input and the golden asset
+// expected after the transform. The values chosen by the script, the
+// script_engine label and the catch_phrase of the entities, are not in the
+// golden asset; the requirements check for them separately.
+package conformance
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+//go:embed testdata/*.json
+var testdata embed.FS
+
+// URLPrefix is prefixed to the name of the asset by the URLer passed to the
+// engines.
+const URLPrefix = "https://conformance.example.com/"
+
+// Case is an input asset with the golden asset expected after the
+// transform.
+type Case struct {
+	Name  string
+	Input *asset.Asset
+	Want  *asset.Asset
+}
+
+// Cases loads the cases from testdata, sorted by name.
+func Cases() ([]Case, error) {
+	const op = "conformance.Cases"
+
+	inputs, err := testdata.ReadDir("testdata")
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	var cases []Case
+	for _, f := range inputs {
+		name := strings.TrimSuffix(f.Name(), ".input.json")
+		if name == f.Name() {
+			continue
+		}
+
+		c := Case{Name: name}
+		if c.Input, err = load(name + ".input.json"); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		if c.Want, err = load(name + ".golden.json"); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		cases = append(cases, c)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+
+	return cases, nil
+}
+
+func load(name string) (*asset.Asset, error) {
+	const op = "conformance.load"
+
+	b, err := testdata.ReadFile(path.Join("testdata", name))
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	a, err := assetio.Unmarshal(b, assetio.FormatJSON)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithTextf("decode %s", name), errors.WithErr(err))
+	}
+
+	return a, nil
+}
+
+// Result of checking a requirement for one case.
+type Result struct {
+	Engine      string
+	Case        string
+	Requirement string
+	// Err is nil if the requirement is met.
+	Err error
+}
+
+// Report holds the results of running the cases against the engines.
+type Report struct {
+	Engines []string
+	Results []Result
+}
+
+// Run runs every case against each of the named engines, or all the
+// registered engines if names is empty. A script which fails to transform
+// a case fails every requirement for it. Each engine is also run with a
+// script which does nothing over the sample asset of every data type, for
+// the round_trip requirement, with a script which fails after changing the
+// asset over every case, for the atomic requirement, and with the default
+// script over generated assets, for the generated requirement. The returned
+// error is non-nil only if the cases cannot be loaded or an engine cannot
+// be built.
+func Run(ctx context.Context, names ...string) (Report, error) {
+	const op = "conformance.Run"
+
+	if len(names) == 0 {
+		names = engine.Names()
+	}
+
+	cases, err := Cases()
+	if err != nil {
+		return Report{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	opts := engine.Options{URLer: func(name string) string { return URLPrefix + name }}
+	r := Report{Engines: names}
+	for _, name := range names {
+		t, err := engine.New(name, opts)
+		if err != nil {
+			return Report{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+
+		for _, c := range cases {
+			got := proto.Clone(c.Input).(*asset.Asset)
+			tErr := t.T(ctx, got)
+
+			for _, req := range Requirements {
+				if req.check == nil {
+					continue
+				}
+				res := Result{Engine: name, Case: c.Name, Requirement: req.Name}
+				if tErr != nil {
+					res.Err = errors.E(errors.WithText("transform"), errors.WithErr(tErr))
+				} else {
+					res.Err = req.check(name, got, c.Want)
+				}
+				r.Results = append(r.Results, res)
+			}
+		}
+
+		results, err := roundTrip(ctx, name, opts)
+		if err != nil {
+			return Report{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		r.Results = append(r.Results, results...)
+
+		if results, err = atomic(ctx, name, cases, opts); err != nil {
+			return Report{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		r.Results = append(r.Results, results...)
+
+		if results, err = generated(ctx, name, opts); err != nil {
+			return Report{}, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		r.Results = append(r.Results, results...)
+	}
+
+	return r, nil
+}
+
+// Failures returns the results for which a requirement was not met.
+func (r Report) Failures() []Result {
+	var failures []Result
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failures = append(failures, res)
+		}
+	}
+	return failures
+}
+
+// Passed reports whether every engine met every requirement.
+func (r Report) Passed() bool { return len(r.Failures()) == 0 }
+
+// WriteMatrix writes a table with a row for each requirement and a column
+// for each engine. A cell is PASS if the engine met the requirement in all
+// the cases.
+func (r Report) WriteMatrix(w io.Writer) error {
+	const op = "Report.WriteMatrix"
+
+	failed := make(map[[2]string]bool)
+	for _, res := range r.Failures() {
+		failed[[2]string{res.Requirement, res.Engine}] = true
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "requirement\t%s\t\n", strings.Join(r.Engines, "\t"))
+	for _, req := range Requirements {
+		fmt.Fprint(tw, req.Name)
+		for _, name := range r.Engines {
+			status := "PASS"
+			if failed[[2]string{req.Name, name}] {
+				status = "FAIL"
+			}
+			fmt.Fprintf(tw, "\t%s", status)
+		}
+		fmt.Fprintln(tw, "\t")
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package conformance_test
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/sudo-suhas/play-script-engine/anko"
+	_ "github.com/sudo-suhas/play-script-engine/bloblang"
+	"github.com/sudo-suhas/play-script-engine/conformance"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	_ "github.com/sudo-suhas/play-script-engine/goja"
+	_ "github.com/sudo-suhas/play-script-engine/gojq"
+	_ "github.com/sudo-suhas/play-script-engine/golua"
+	_ "github.com/sudo-suhas/play-script-engine/gopherlua"
+	_ "github.com/sudo-suhas/play-script-engine/otto"
+	_ "github.com/sudo-suhas/play-script-engine/tengo"
+)
+
+// TestRun checks that every registered engine meets every requirement in
+// each case, as the conformance subcommand does.
+func TestRun(t *testing.T) {
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			report, err := conformance.Run(context.Background(), name)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			for _, res := range report.Results {
+				res := res
+				t.Run(res.Requirement+"/"+res.Case, func(t *testing.T) {
+					if res.Err != nil {
+						t.Error(res.Err)
+					}
+				})
+			}
+		})
+	}
+}
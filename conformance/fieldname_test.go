@@ -0,0 +1,52 @@
+package conformance_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// goNameScripts write to the first feature by the Go name of its field,
+// EntityName, instead of its proto name, entity_name, by engine.
+var goNameScripts = map[string]engine.ScriptString{
+	"anko":      `asset.data.features[0].EntityName = "go"`,
+	"bloblang":  `asset.data.features.0.EntityName = "go"`,
+	"goja":      `asset.data.features[0].EntityName = "go";`,
+	"gojq":      `.data.features[0].EntityName = "go"`,
+	"golua":     `asset.data.features[1].EntityName = "go"`,
+	"gopherlua": `asset.data.features[1].EntityName = "go"`,
+	"otto":      `asset.data.features[0].EntityName = "go";`,
+	"tengo":     `asset.data.features[0].EntityName = "go"`,
+}
+
+// TestGoFieldName checks that every engine names the fields by their proto
+// names only, so that a write by the Go name of a field is rejected as one
+// to a field which does not exist, in strict mode for goja and otto.
+func TestGoFieldName(t *testing.T) {
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			script, ok := goNameScripts[name]
+			if !ok {
+				t.Fatalf("no Go name script for engine %s", name)
+			}
+			tr, err := engine.New(name, engine.Options{Script: script, Strict: true})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+
+			err = tr.T(context.Background(), a)
+			if err == nil || !strings.Contains(err.Error(), "EntityName") {
+				t.Fatalf("T() error = %v, want an error naming EntityName", err)
+			}
+		})
+	}
+}
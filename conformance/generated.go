@@ -0,0 +1,48 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+const (
+	// generatedSeed seeds the generator so that a failure can be
+	// reproduced.
+	generatedSeed = 1
+	// generatedAssets is the number of generated assets.
+	generatedAssets = 100
+)
+
+// generated runs the default script of the engine over assets built by
+// sample.Generator, which vary in shapes such as a nil lineage or lists,
+// that the script must accept. The case of a result is the index of the
+// asset.
+func generated(ctx context.Context, name string, opts engine.Options) ([]Result, error) {
+	const op = "conformance.generated"
+
+	assets, err := sample.NewGenerator(generatedSeed, sample.GenOptions{}).Assets(generatedAssets)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	t, err := engine.New(name, opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	results := make([]Result, len(assets))
+	for i, a := range assets {
+		res := Result{Engine: name, Case: fmt.Sprintf("asset_%d", i), Requirement: "generated"}
+		if err := t.T(ctx, a); err != nil {
+			res.Err = errors.E(errors.WithText("transform"), errors.WithErr(err))
+		}
+		results[i] = res
+	}
+
+	return results, nil
+}
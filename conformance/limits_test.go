@@ -0,0 +1,85 @@
+package conformance_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// limitScripts exceed the limit, by the limit and the engine. An engine
+// missing from the scripts of a limit must refuse it.
+var limitScripts = map[string]map[string]engine.ScriptString{
+	engine.LimitMaxAllocs: {
+		"tengo":     `a := []; for { a = append(a, 1) }`,
+	},
+	engine.LimitMaxSteps: {
+		"anko":      `for { }`,
+		"gojq":      `last(repeat(.))`,
+		"golua":     `while true do end`,
+		"gopherlua": `while true do end`,
+		"otto":      `for (;;) {}`,
+	},
+	engine.LimitMaxCallDepth: {
+		"goja":      `function f() { f(); } f();`,
+		"golua":     `local function f() f() end f()`,
+		"gopherlua": `local function f() f() end f()`,
+		"otto":      `function f() { f(); } f();`,
+	},
+}
+
+// TestLimits checks that every engine either refuses a limit or stops a
+// script which exceeds it with a *engine.LimitError. MaxOutputSize is
+// checked for every engine with its default script.
+func TestLimits(t *testing.T) {
+	limits := map[string]engine.Limits{
+		engine.LimitMaxAllocs:     {MaxAllocs: 1000},
+		engine.LimitMaxSteps:      {MaxSteps: 1000},
+		engine.LimitMaxCallDepth:  {MaxCallDepth: 100},
+		engine.LimitMaxOutputSize: {MaxOutputSize: 1},
+	}
+
+	for _, name := range engine.Names() {
+		for limit, l := range limits {
+			name, limit, l := name, limit, l
+			t.Run(name+"/"+limit, func(t *testing.T) {
+				t.Parallel()
+
+				script, ok := limitScripts[limit][name]
+				if !ok && limit != engine.LimitMaxOutputSize {
+					_, err := engine.New(name, engine.Options{Script: engine.ScriptString("x"), Limits: l})
+					if errors.WhatKind(err) != errors.InvalidInput {
+						t.Fatalf("New() error = %v, want kind %v for a limit without a script", err, errors.InvalidInput)
+					}
+					return
+				}
+
+				opts := engine.Options{URLer: func(s string) string { return s }, Limits: l}
+				if ok {
+					opts.Script = script
+				}
+				tr, err := engine.New(name, opts)
+				if err != nil {
+					t.Fatalf("New() error = %v", err)
+				}
+
+				a, err := sample.FeatureTable()
+				if err != nil {
+					t.Fatalf("sample.FeatureTable() error = %v", err)
+				}
+
+				err = tr.T(context.Background(), a)
+				if errors.WhatKind(err) != errors.ResourceExhausted {
+					t.Fatalf("T() error = %v, want kind %v", err, errors.ResourceExhausted)
+				}
+				var lErr *engine.LimitError
+				if !errors.As(err, &lErr) || lErr.Limit != limit {
+					t.Errorf("T() error = %v, want *engine.LimitError for %s", err, limit)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package conformance_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// urlerScripts call the URLer, by engine.
+var urlerScripts = map[string]engine.ScriptString{
+	"anko":      `asset.url = urler(asset.name)`,
+	"bloblang":  `asset.url = urler(asset.name)`,
+	"goja":      `asset.url = urler(asset.name);`,
+	"gojq":      `.url = urler(.name)`,
+	"golua":     `asset.url = urler(asset.name)`,
+	"gopherlua": `asset.url = urler(asset.name)`,
+	"otto":      `asset.url = urler(asset.name);`,
+	"tengo":     `asset.url = urler(asset.name)`,
+}
+
+func panickingURLer(string) string { panic("urler: boom") }
+
+func TestURLerPanic(t *testing.T) {
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			script, ok := urlerScripts[name]
+			if !ok {
+				t.Fatalf("no urler script for engine %s", name)
+			}
+			tr, err := engine.New(name, engine.Options{Script: script, URLer: panickingURLer})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+			a.Urn = "urn:panic"
+
+			err = tr.T(context.Background(), a)
+			if errors.WhatKind(err) != errors.Internal {
+				t.Fatalf("T() error = %v, want kind %v", err, errors.Internal)
+			}
+
+			var e *errors.Error
+			if !errors.As(err, &e) || !contains(e.Ops(), name+".Transform") {
+				t.Errorf("T() error = %v, want op %s.Transform", err, name)
+			}
+
+			var pErr *engine.PanicError
+			if !errors.As(err, &pErr) {
+				t.Fatalf("T() error = %v, want *engine.PanicError", err)
+			}
+			if pErr.Engine != name || pErr.URN != a.Urn || pErr.Value != "urler: boom" {
+				t.Errorf("T() error = %+v, want engine %s, URN %s and the value passed to panic", pErr, name, a.Urn)
+			}
+			if !bytes.Contains(pErr.Stack, []byte("panickingURLer")) {
+				t.Errorf("T() stack = %s, want the frame of the URLer", pErr.Stack)
+			}
+		})
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package conformance_test
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/protoset"
+)
+
+// dynamicScripts change the data of the sample model, which is a dynamic
+// message, by engine.
+var dynamicScripts = map[string]engine.ScriptString{
+	"anko": `
+asset.data.namespace = "changed"
+asset.data.versions[1].status = "retired"
+`,
+	"bloblang": `
+asset.data.namespace = "changed"
+asset.data.versions = asset.data.versions.map_each(v -> if v.number == 2 { v.assign({"status": "retired"}) } else { v })
+`,
+	"goja": `
+asset.data.namespace = "changed";
+asset.data.versions[1].status = "retired";
+`,
+	"gojq": `.data.namespace = "changed" | .data.versions[1].status = "retired"`,
+	"golua": `
+asset.data.namespace = "changed"
+asset.data.versions[2].status = "retired"
+`,
+	"gopherlua": `
+asset.data.namespace = "changed"
+asset.data.versions[2].status = "retired"
+`,
+	"otto": `
+asset.data.namespace = "changed";
+asset.data.versions[1].status = "retired";
+`,
+	"tengo": `
+asset.data.namespace = "changed"
+asset.data.versions[1].status = "retired"
+`,
+}
+
+// TestDynamicData checks that every engine transforms the data of an asset
+// which holds a message registered from a FileDescriptorSet, with its
+// timestamps, durations, enums and maps, instead of a compiled one.
+func TestDynamicData(t *testing.T) {
+	if err := protoset.Register("../sample/testdata/model.binpb"); err != nil {
+		t.Fatalf("protoset.Register() error = %v", err)
+	}
+
+	in, err := assetio.ReadFile("../sample/testdata/model.json", assetio.FormatJSON)
+	if err != nil {
+		t.Fatalf("assetio.ReadFile() error = %v", err)
+	}
+	wantData, err := in.Data.UnmarshalNew()
+	if err != nil {
+		t.Fatalf("UnmarshalNew() error = %v", err)
+	}
+	if _, ok := wantData.(*dynamicpb.Message); !ok {
+		t.Fatalf("data = %T, want *dynamicpb.Message", wantData)
+	}
+	fields := wantData.ProtoReflect().Descriptor().Fields()
+	wantData.ProtoReflect().Set(fields.ByName("namespace"), protoreflect.ValueOfString("changed"))
+	versions := wantData.ProtoReflect().Get(fields.ByName("versions")).List()
+	v := versions.Get(1).Message()
+	v.Set(v.Descriptor().Fields().ByName("status"), protoreflect.ValueOfString("retired"))
+
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			script, ok := dynamicScripts[name]
+			if !ok {
+				t.Fatalf("no dynamic script for engine %s", name)
+			}
+			tr, err := engine.New(name, engine.Options{Script: script, URLer: func(s string) string { return s }})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a := proto.Clone(in).(*asset.Asset)
+			if err := tr.T(context.Background(), a); err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+
+			if a.Data.GetTypeUrl() != in.Data.GetTypeUrl() {
+				t.Errorf("T() data type = %s, want %s", a.Data.GetTypeUrl(), in.Data.GetTypeUrl())
+			}
+			got, err := a.Data.UnmarshalNew()
+			if err != nil {
+				t.Fatalf("UnmarshalNew() error = %v", err)
+			}
+			if !proto.Equal(got, wantData) {
+				t.Errorf("T() data = {%v}, want {%v}", got, wantData)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package conformance
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Requirement is one of the requirements documented on engine.Transformer.
+type Requirement struct {
+	Name        string
+	Description string
+
+	// check returns an error describing how the transformed asset got
+	// differs from the golden asset want. It is nil for round_trip, atomic
+	// and generated.
+	check func(engine string, got, want *asset.Asset) error
+}
+
+// Requirements checked for each case, in the order they are documented.
+var Requirements = []Requirement{
+	{
+		Name:        "asset_label",
+		Description: `add the label "script_engine": "<engine>" to the asset`,
+		check:       checkAssetLabel,
+	},
+	{
+		Name:        "entity_labels",
+		Description: `add the label "catch_phrase" to each entity`,
+		check:       checkEntityLabels,
+	},
+	{
+		Name:        "entity_names",
+		Description: "set the entity name of each feature from the mapping",
+		check:       checkEntityNames,
+	},
+	{
+		Name:        "owner",
+		Description: "add the owner Big Mom",
+		check:       checkOwner,
+	},
+	{
+		Name:        "url",
+		Description: "set the URL using the urler function",
+		check:       checkURL,
+	},
+	{
+		Name:        "upstream_urns",
+		Description: "remove .yonkou.io from the URN of kafka upstreams",
+		check:       checkUpstreamURNs,
+	},
+	{
+		Name:        "unchanged",
+		Description: "leave the other fields of the asset unchanged",
+		check:       checkUnchanged,
+	},
+	{
+		Name:        "round_trip",
+		Description: "leave the sample asset of every data type unchanged with a script which does nothing",
+		// Checked over the samples by roundTrip rather than for each case.
+	},
+	{
+		Name:        "atomic",
+		Description: "leave the asset unchanged when the script fails after changing it",
+		// Checked with a failing script by atomic rather than for each
+		// case.
+	},
+	{
+		Name:        "generated",
+		Description: "transform the assets built by sample.Generator with the default script",
+		// Checked over the generated assets by generated rather than for
+		// each case.
+	},
+}
+
+func checkAssetLabel(engine string, got, want *asset.Asset) error {
+	labels := map[string]string{"script_engine": engine}
+	for k, v := range want.Labels {
+		labels[k] = v
+	}
+
+	return diff("labels", got.Labels, labels)
+}
+
+func checkEntityLabels(_ string, got, want *asset.Asset) error {
+	gotData, wantData, err := featureTables(got, want)
+	if err != nil {
+		return err
+	}
+
+	if len(gotData.Entities) != len(wantData.Entities) {
+		return errors.E(errors.WithTextf("got %d entities, want %d", len(gotData.Entities), len(wantData.Entities)))
+	}
+
+	for i, e := range gotData.Entities {
+		if e.Labels["catch_phrase"] == "" {
+			return errors.E(errors.WithTextf("entities[%d]: missing label catch_phrase", i))
+		}
+
+		labels := make(map[string]string, len(e.Labels))
+		for k, v := range e.Labels {
+			if k != "catch_phrase" {
+				labels[k] = v
+			}
+		}
+		if err := diff(fmt.Sprintf("entities[%d].labels", i), labels, wantData.Entities[i].Labels); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkEntityNames(_ string, got, want *asset.Asset) error {
+	gotData, wantData, err := featureTables(got, want)
+	if err != nil {
+		return err
+	}
+
+	if len(gotData.Features) != len(wantData.Features) {
+		return errors.E(errors.WithTextf("got %d features, want %d", len(gotData.Features), len(wantData.Features)))
+	}
+
+	for i, f := range gotData.Features {
+		if !proto.Equal(f, wantData.Features[i]) {
+			return errors.E(errors.WithTextf("features[%d]: got {%v}, want {%v}", i, f, wantData.Features[i]))
+		}
+	}
+
+	return nil
+}
+
+// checkOwner does not check the position of the owner added by the script.
+func checkOwner(_ string, got, want *asset.Asset) error {
+	if len(got.Owners) != len(want.Owners) {
+		return errors.E(errors.WithTextf("got owners %v, want %v", got.Owners, want.Owners))
+	}
+
+	matched := make([]bool, len(got.Owners))
+	for _, w := range want.Owners {
+		var found bool
+		for i, g := range got.Owners {
+			if !matched[i] && proto.Equal(g, w) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return errors.E(errors.WithTextf("missing owner {%v} in %v", w, got.Owners))
+		}
+	}
+
+	return nil
+}
+
+func checkURL(_ string, got, want *asset.Asset) error {
+	return diff("url", got.Url, want.Url)
+}
+
+func checkUpstreamURNs(_ string, got, want *asset.Asset) error {
+	gotUpstreams, wantUpstreams := got.GetLineage().GetUpstreams(), want.GetLineage().GetUpstreams()
+	if len(gotUpstreams) != len(wantUpstreams) {
+		return errors.E(errors.WithTextf("got %d upstreams, want %d", len(gotUpstreams), len(wantUpstreams)))
+	}
+
+	for i, u := range gotUpstreams {
+		if !proto.Equal(u, wantUpstreams[i]) {
+			return errors.E(errors.WithTextf("upstreams[%d]: got {%v}, want {%v}", i, u, wantUpstreams[i]))
+		}
+	}
+
+	return nil
+}
+
+// checkUnchanged compares the fields which are not covered by the other
+// requirements.
+func checkUnchanged(_ string, got, want *asset.Asset) error {
+	gotData, wantData, err := featureTables(got, want)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range []*asset.FeatureTable{gotData, wantData} {
+		data.Entities, data.Features = nil, nil
+	}
+	if !proto.Equal(gotData, wantData) {
+		return errors.E(errors.WithTextf("data: got {%v}, want {%v}", gotData, wantData))
+	}
+
+	got, want = proto.Clone(got).(*asset.Asset), proto.Clone(want).(*asset.Asset)
+	for _, a := range []*asset.Asset{got, want} {
+		a.Labels, a.Owners, a.Url, a.Data = nil, nil, "", nil
+		if a.Lineage != nil {
+			a.Lineage.Upstreams = nil
+		}
+	}
+	if !proto.Equal(got, want) {
+		return errors.E(errors.WithTextf("got {%v}, want {%v}", got, want))
+	}
+
+	return nil
+}
+
+// featureTables unmarshals the data of the assets.
+func featureTables(got, want *asset.Asset) (gotData, wantData *asset.FeatureTable, err error) {
+	gotData, wantData = &asset.FeatureTable{}, &asset.FeatureTable{}
+	if err := got.GetData().UnmarshalTo(gotData); err != nil {
+		return nil, nil, errors.E(errors.WithText("decode data"), errors.WithErr(err))
+	}
+	if err := want.GetData().UnmarshalTo(wantData); err != nil {
+		return nil, nil, errors.E(errors.WithText("decode golden data"), errors.WithErr(err))
+	}
+
+	return gotData, wantData, nil
+}
+
+func diff(field string, got, want interface{}) error {
+	if reflect.DeepEqual(got, want) || isEmpty(got) && isEmpty(want) {
+		return nil
+	}
+
+	return errors.E(errors.WithTextf("%s: got %v, want %v", field, got, want))
+}
+
+// isEmpty treats a nil and an empty map as equal.
+func isEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Map && rv.Len() == 0
+}
@@ -0,0 +1,80 @@
+package conformance
+
+import (
+	"context"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// noopScripts leave the asset as it is, by the engine. The engines missing
+// from it accept an empty script.
+var noopScripts = map[string]engine.ScriptString{
+	"bloblang": "root = this",
+	"gojq":     ".",
+}
+
+// roundTrip runs a script which does nothing over the sample asset of each
+// data type, so the asset and its data must go through the conversions of
+// the engine unchanged. The case of a result is the type of the asset.
+func roundTrip(ctx context.Context, name string, opts engine.Options) ([]Result, error) {
+	const op = "conformance.roundTrip"
+
+	samples, err := sample.Assets()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	opts.Script = noopScripts[name]
+	t, err := engine.New(name, opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	results := make([]Result, len(samples))
+	for i, want := range samples {
+		got := proto.Clone(want).(*asset.Asset)
+		res := Result{Engine: name, Case: want.Type, Requirement: "round_trip"}
+		if err := t.T(ctx, got); err != nil {
+			res.Err = errors.E(errors.WithText("transform"), errors.WithErr(err))
+		} else {
+			res.Err = checkEqual(got, want)
+		}
+		results[i] = res
+	}
+
+	return results, nil
+}
+
+// checkEqual compares the assets and their unpacked data. The data cannot
+// be compared packed, the order of the map entries in the bytes of an Any is
+// not deterministic.
+func checkEqual(got, want *asset.Asset) error {
+	if got.GetData().GetTypeUrl() != want.GetData().GetTypeUrl() {
+		return errors.E(errors.WithTextf("data: got type %s, want %s", got.GetData().GetTypeUrl(), want.GetData().GetTypeUrl()))
+	}
+
+	gotData, err := got.GetData().UnmarshalNew()
+	if err != nil {
+		return errors.E(errors.WithText("decode data"), errors.WithErr(err))
+	}
+	wantData, err := want.GetData().UnmarshalNew()
+	if err != nil {
+		return errors.E(errors.WithText("decode sample data"), errors.WithErr(err))
+	}
+	if !proto.Equal(gotData, wantData) {
+		return errors.E(errors.WithTextf("data: got {%v}, want {%v}", gotData, wantData))
+	}
+
+	got, want = proto.Clone(got).(*asset.Asset), proto.Clone(want).(*asset.Asset)
+	got.Data, want.Data = nil, nil
+	if !proto.Equal(got, want) {
+		return errors.E(errors.WithTextf("got {%v}, want {%v}", got, want))
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package conformance_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// strictScripts write to asset.lables, a typo of asset.labels, on their
+// second line, by engine.
+var strictScripts = map[string]engine.ScriptString{
+	"anko":      "asset.name = \"typo\"\nasset.lables = {\"team\": \"data\"}",
+	"bloblang":  "asset.name = \"typo\"\nasset.lables = {\"team\": \"data\"}",
+	"goja":      "asset.name = \"typo\";\nasset.lables = {team: \"data\"};",
+	"gojq":      ".name = \"typo\"\n| .lables = {\"team\": \"data\"}",
+	"golua":     "asset.name = \"typo\"\nasset.lables = {team = \"data\"}",
+	"gopherlua": "asset.name = \"typo\"\nasset.lables = {team = \"data\"}",
+	"otto":      "asset.name = \"typo\";\nasset.lables = {team: \"data\"};",
+	"tengo":     "asset.name = \"typo\"\nasset.lables = {team: \"data\"}",
+}
+
+// strictLines are the locations of the write in the error, by engine. The
+// engines which work on maps only find the field once the script has run.
+var strictLines = map[string]string{
+	"goja":      "script.js:2:",
+	"gopherlua": "script.lua:2:",
+	"otto":      "script.js:2:",
+	"tengo":     "(main):2:",
+}
+
+// TestStrict checks that every engine rejects a write to a field which
+// does not exist in strict mode, with an error naming the field and, if
+// the engine can tell, its line.
+func TestStrict(t *testing.T) {
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			script, ok := strictScripts[name]
+			if !ok {
+				t.Fatalf("no strict script for engine %s", name)
+			}
+			tr, err := engine.New(name, engine.Options{Script: script, Strict: true})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+
+			err = tr.T(context.Background(), a)
+			if err == nil || !strings.Contains(err.Error(), "lables") {
+				t.Fatalf("T() error = %v, want an error naming lables", err)
+			}
+			if !strings.Contains(err.Error(), strictLines[name]) {
+				t.Errorf("T() error = %v, want the location %s", err, strictLines[name])
+			}
+			if a.Name == "typo" {
+				t.Errorf("T() changed the asset to %v", a)
+			}
+		})
+	}
+}
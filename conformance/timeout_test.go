@@ -0,0 +1,69 @@
+package conformance_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// timeoutScripts never finish, by engine. A bloblang mapping cannot loop,
+// so it is stuck in the urler of the test instead.
+var timeoutScripts = map[string]engine.ScriptString{
+	"anko":      `for { }`,
+	"bloblang":  `asset.url = urler(asset.name)`,
+	"goja":      `for (;;) {}`,
+	"gojq":      `def loop: loop; loop`,
+	"golua":     `while true do end`,
+	"gopherlua": `while true do end`,
+	"otto":      `for (;;) {}`,
+	"tengo":     `for {}`,
+}
+
+// TestTimeout checks that every engine stops a script which does not
+// finish before the deadline of the context, with an error of the kind
+// errors.DeadlineExceeded, and leaves the asset unchanged.
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	stuckURLer := func(s string) string {
+		<-release
+		return s
+	}
+
+	for _, name := range engine.Names() {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			script, ok := timeoutScripts[name]
+			if !ok {
+				t.Fatalf("no timeout script for engine %s", name)
+			}
+			tr, err := engine.New(name, engine.Options{Script: script, URLer: stuckURLer})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+			want := proto.Clone(a)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			err = tr.T(ctx, a)
+			if errors.WhatKind(err) != errors.DeadlineExceeded {
+				t.Errorf("T() error = %v, want kind %v", err, errors.DeadlineExceeded)
+			}
+			if !proto.Equal(a, want) {
+				t.Errorf("T() changed the asset to %v", a)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// ContextError returns a non-nil error if the context is done. The error
+// wraps ctx.Err() and is of the kind errors.DeadlineExceeded or
+// errors.Canceled so that the script being stopped can be told apart from
+// the script failing:
+//
+//	if errors.WhatKind(err) == errors.DeadlineExceeded {
+//		// The script did not finish in time.
+//	}
+func ContextError(ctx context.Context) error {
+	const op = "engine.ContextError"
+
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+
+	kind := errors.Canceled
+	if err == context.DeadlineExceeded {
+		kind = errors.DeadlineExceeded
+	}
+	return errors.E(errors.WithOp(op), kind, errors.WithText("script stopped"), errors.WithErr(err))
+}
+
+// Interrupt calls interrupt once if the context is done before the returned
+// stop function is called. stop waits for a call to interrupt that is in
+// progress to return, so that it is safe to reset the state touched by
+// interrupt after stop returns. stop can be called more than once, which
+// lets it be deferred as well so that a panic out of the script does not
+// leak the goroutine. It is meant for engines which can only be stopped
+// from another goroutine.
+func Interrupt(ctx context.Context, interrupt func()) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	var (
+		stopped = make(chan struct{})
+		exited  = make(chan struct{})
+		once    sync.Once
+	)
+	go func() {
+		defer close(exited)
+
+		select {
+		case <-ctx.Done():
+			interrupt()
+		case <-stopped:
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(stopped) })
+		<-exited
+	}
+}
+
+// WithTimeout returns a Transformer which stops the script run by t if it
+// takes longer than d. The returned error is then of the kind
+// errors.DeadlineExceeded. If d is not positive, t is returned as is.
+func WithTimeout(t Transformer, d time.Duration) Transformer {
+	if d <= 0 {
+		return t
+	}
+
+	return timeoutTransformer{t: t, d: d}
+}
+
+type timeoutTransformer struct {
+	t Transformer
+	d time.Duration
+}
+
+func (t timeoutTransformer) T(ctx context.Context, a *asset.Asset) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+
+	return t.t.T(ctx, a)
+}
+
+// Expand runs Expand for t with the timeout, which falls back to T if t is
+// not an Expander.
+func (t timeoutTransformer) Expand(ctx context.Context, a *asset.Asset) ([]*asset.Asset, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+
+	return Expand(ctx, t.t, a)
+}
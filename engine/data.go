@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// PackData replaces the data of the asset with the message unpacked from
+// it, which the script sees as asset.data. Unlike anypb.New, it keeps the
+// type URL of the data as is, so that a custom prefix survives the
+// transform.
+func PackData(a *asset.Asset, data proto.Message) error {
+	const op = "engine.PackData"
+
+	b, err := proto.Marshal(data)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	a.Data = &anypb.Any{TypeUrl: a.Data.GetTypeUrl(), Value: b}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Package engine defines the contract implemented by each of the script
+// engines and a registry through which the engines can be looked up by name.
+//
+// Engines register themselves when their package is imported:
+//
+//	import _ "github.com/sudo-suhas/play-script-engine/goja"
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Transformer runs a script against an asset and modifies it in place.
+// Implementations must be safe for concurrent use by multiple goroutines
+// and must not leak state from one call to the next.
+type Transformer interface {
+	// T should do the following:
+	// - Add a label to the asset - "script_engine": "<current_script_engine>"
+	// - Add a label to each entity. Ex: "catch_phrase": "..."
+	// - Set an EntityName for each feature based on the following table
+	//   - ongoing_placed_and_waiting_acceptance_orders: customer_orders
+	//   - ongoing_orders: customer_orders
+	//   - merchant_avg_dispatch_arrival_time_10m: merchant_driver
+	//   - ongoing_accepted_orders: merchant_orders
+	// - Set the owner as {Name: Big Mom, Email: big.mom@wholecakeisland.com}
+	// - Set the Url using a function that is passed in
+	// - For each lineage upstream, if the service is Kafka, apply a string
+	//   replace on the URN - {.yonkou.io => }.
+	T(ctx context.Context, a *asset.Asset) error
+}
+
+// Options are used by a Factory for building the Transformer.
+type Options struct {
+	// URLer is exposed to the script as the function urler for building
+	// the URL of the asset.
+	URLer func(string) string
+
+	// Script is run by the Transformer. The default script of the engine,
+	// which fulfils the requirements documented on Transformer, is used if
+	// it is nil.
+	Script Script
+
+	// Limits bound the resources used by each run of the script.
+	Limits Limits
+
+	// Strict makes a write to a field which does not exist, such as
+	// asset.lables, an error naming the field instead of doing nothing.
+	// The engines which work on maps, and tengo, gopher-lua and anko, always
+	// reject such writes. goja runs the script in strict mode and otto,
+	// which cannot trap the write, checks for it before each statement.
+	Strict bool
+
+	// Output receives what the script prints, such as with print in Lua
+	// or console.log in otto. See Options.Printer.
+	Output io.Writer
+
+	// FanOut makes Expand return every asset produced by a script which
+	// produces more than one, which only gojq can, instead of an error.
+	// T always rejects such a script.
+	FanOut bool
+}
+
+// Factory builds a Transformer for the given Options.
+type Factory func(Options) (Transformer, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a script engine available by the provided name. If
+// Register is called twice with the same name or if factory is nil, it
+// panics.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if factory == nil {
+		panic("engine: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("engine: Register called twice for engine " + name)
+	}
+
+	factories[name] = factory
+}
+
+// Names returns a sorted list of the names of the registered engines.
+func Names() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the Transformer for the engine registered with the given
+// name. If there is no such engine, the returned error wraps an
+// *UnknownEngineError.
+func New(name string, opts Options) (Transformer, error) {
+	const op = "engine.New"
+
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, errors.E(
+			errors.WithOp(op), errors.NotFound,
+			errors.WithErr(&UnknownEngineError{Name: name, Available: Names()}),
+		)
+	}
+
+	t, err := factory(opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithTextf("init %s", name), errors.WithErr(err))
+	}
+
+	return t, nil
+}
+
+// UnknownEngineError is returned when there is no engine registered by
+// the requested name.
+type UnknownEngineError struct {
+	Name      string
+	Available []string
+}
+
+func (e *UnknownEngineError) Error() string {
+	return fmt.Sprintf(
+		"unknown script engine: %s (available: %s)", e.Name, strings.Join(e.Available, ", "),
+	)
+}
+
+// Stage returns a clone of the asset for the script to modify and a
+// function which replaces the contents of the asset with the clone.
+// Transformers call commit only once the script has succeeded, so that the
+// asset is left unchanged on error.
+func Stage(a *asset.Asset) (clone *asset.Asset, commit func()) {
+	clone = proto.Clone(a).(*asset.Asset)
+	return clone, func() {
+		proto.Reset(a)
+		proto.Merge(a, clone)
+	}
+}
@@ -0,0 +1,62 @@
+package engine_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+type nopTransformer struct{}
+
+func (nopTransformer) T(context.Context, *asset.Asset) error { return nil }
+
+func init() {
+	for _, name := range []string{"test_b", "test_a"} {
+		engine.Register(name, func(engine.Options) (engine.Transformer, error) {
+			return nopTransformer{}, nil
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := engine.New("test_a", engine.Options{}); err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, err := engine.New("nope", engine.Options{})
+	if errors.WhatKind(err) != errors.NotFound {
+		t.Errorf("New() error = %v, want kind %v", err, errors.NotFound)
+	}
+	var uErr *engine.UnknownEngineError
+	if !errors.As(err, &uErr) {
+		t.Fatalf("New() error = %v, want *engine.UnknownEngineError", err)
+	}
+	if uErr.Name != "nope" || strings.Join(uErr.Available, ",") != "test_a,test_b" {
+		t.Errorf("New() error = %+v, want the engine nope and the available test_a, test_b", uErr)
+	}
+	if !strings.Contains(err.Error(), "available: test_a, test_b") {
+		t.Errorf("New() error = %q, want the available engines listed", err)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	for name, factory := range map[string]engine.Factory{
+		"test_a":   func(engine.Options) (engine.Transformer, error) { return nopTransformer{}, nil },
+		"test_nil": nil,
+	} {
+		name, factory := name, factory
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			engine.Register(name, factory)
+		})
+	}
+}
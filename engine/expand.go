@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Expander is implemented by a Transformer whose script can produce any
+// number of assets from the one it is given, as a jq query can.
+type Expander interface {
+	// Expand runs the script against the asset and returns the assets it
+	// produced, in order, leaving the asset unchanged. It returns none if
+	// the script dropped the asset. If the script produced more than one
+	// asset and Options.FanOut is not set, the error wraps a
+	// *MultipleAssetsError.
+	Expand(ctx context.Context, a *asset.Asset) ([]*asset.Asset, error)
+}
+
+// Expand returns the assets produced by t from the asset. If t is not an
+// Expander, the asset is transformed in place and returned as the only
+// asset.
+func Expand(ctx context.Context, t Transformer, a *asset.Asset) ([]*asset.Asset, error) {
+	if e, ok := t.(Expander); ok {
+		return e.Expand(ctx, a)
+	}
+
+	if err := t.T(ctx, a); err != nil {
+		return nil, err
+	}
+	return []*asset.Asset{a}, nil
+}
+
+// DroppedError is returned by T if the script produced no asset, such as
+// with empty in jq. Expand returns no assets instead.
+type DroppedError struct {
+	// URN of the asset dropped by the script.
+	URN string
+}
+
+func (e *DroppedError) Error() string {
+	return fmt.Sprintf("script dropped asset %q", e.URN)
+}
+
+// MultipleAssetsError is returned if the script produced more than one
+// asset where only one is expected: by T, and by Expand unless
+// Options.FanOut is set.
+type MultipleAssetsError struct {
+	// URN of the asset given to the script.
+	URN string
+}
+
+func (e *MultipleAssetsError) Error() string {
+	return fmt.Sprintf("script produced more than one asset from %q", e.URN)
+}
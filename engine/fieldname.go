@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldName returns the name under which the scripts of the engines which
+// reflect on Go structs read and write a field of a generated proto
+// message: its proto name, given by the name in the protobuf struct tag,
+// such as entity_name for EntityName. The field holding a oneof is named by
+// the oneof. The fields which are not proto fields, such as the internal
+// state of the message, have no name and are hidden from the scripts.
+func FieldName(f reflect.StructField) string {
+	if name := f.Tag.Get("protobuf_oneof"); name != "" {
+		return name
+	}
+
+	for _, opt := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if name := strings.TrimPrefix(opt, "name="); name != opt {
+			return name
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Names of the limits, as used in LimitError.
+const (
+	LimitMaxAllocs     = "MaxAllocs"
+	LimitMaxSteps      = "MaxSteps"
+	LimitMaxCallDepth  = "MaxCallDepth"
+	LimitMaxOutputSize = "MaxOutputSize"
+)
+
+// Limits bound the resources used by a single run of a script. The zero
+// value of a field means there is no limit.
+//
+// The limits are mapped onto the knobs of each engine. Where an engine has
+// no knob of its own, the Transformer enforces the limit itself if it can,
+// such as counting the steps through CountSteps. Not every engine can
+// enforce every limit:
+//
+//	Limit          Engines
+//	MaxAllocs      tengo
+//	MaxSteps       anko, gojq, golua, gopherlua, otto
+//	MaxCallDepth   goja, golua, gopherlua, otto
+//	MaxOutputSize  all
+//
+// Rather than silently running the script without a limit, the
+// constructor of an engine returns an error of the kind
+// errors.InvalidInput if it is given a limit it cannot enforce.
+type Limits struct {
+	// MaxAllocs is the maximum number of objects allocated by the script.
+	MaxAllocs int64
+
+	// MaxSteps is the maximum number of instructions executed by the
+	// script. A step is a statement or expression for otto and anko, and
+	// an instruction of the compiled query for gojq. golua checks the
+	// count every 1000 instructions, so a script can run over the limit
+	// by as much.
+	MaxSteps int64
+
+	// MaxCallDepth is the maximum depth of the call stack of the script.
+	// golua checks the depth every MaxCallDepth instructions, so the depth
+	// can go over the limit before the script is stopped.
+	MaxCallDepth int
+
+	// MaxOutputSize is the maximum size in bytes of the transformed asset
+	// in the protobuf wire format. It is checked by every engine after the
+	// script has run.
+	MaxOutputSize int
+}
+
+// Supported returns an error of the kind errors.InvalidInput if any limit
+// other than those named, which the engine can enforce, is set.
+// MaxOutputSize is always supported.
+func (l Limits) Supported(engine string, names ...string) error {
+	const op = "Limits.Supported"
+
+	for _, lim := range []struct {
+		name string
+		set  bool
+	}{
+		{LimitMaxAllocs, l.MaxAllocs != 0},
+		{LimitMaxSteps, l.MaxSteps != 0},
+		{LimitMaxCallDepth, l.MaxCallDepth != 0},
+	} {
+		if lim.set && !contains(names, lim.name) {
+			return errors.E(
+				errors.WithOp(op), errors.InvalidInput,
+				errors.WithTextf("limit %s is not supported by engine %s", lim.name, engine),
+			)
+		}
+	}
+
+	return nil
+}
+
+// CheckOutput returns an error wrapping a *LimitError if the size of the
+// asset exceeds MaxOutputSize.
+func (l Limits) CheckOutput(a *asset.Asset) error {
+	if l.MaxOutputSize == 0 || proto.Size(a) <= l.MaxOutputSize {
+		return nil
+	}
+
+	return LimitExceeded(LimitMaxOutputSize, int64(l.MaxOutputSize), nil)
+}
+
+// CountSteps returns a context which is done once its Done method has
+// been called more than max times, or when ctx is done. It enforces
+// MaxSteps for the engines which check the context before each step but
+// have no hook to count them, such as gopher-lua, anko and gojq. exceeded
+// reports whether the context is done because of the count, in which case
+// its Err is errStepLimit rather than that of ctx.
+func CountSteps(ctx context.Context, max int64) (_ context.Context, exceeded func() bool) {
+	c := &stepContext{Context: ctx, max: max, done: make(chan struct{})}
+	return c, func() bool { return atomic.LoadInt64(&c.n) > max }
+}
+
+// errStepLimit is the error of a context returned by CountSteps once the
+// step count has exceeded the limit.
+var errStepLimit = errors.E(errors.WithText("step limit exceeded"))
+
+type stepContext struct {
+	context.Context
+	max  int64
+	n    int64
+	once sync.Once
+	done chan struct{}
+}
+
+func (c *stepContext) Done() <-chan struct{} {
+	if atomic.AddInt64(&c.n, 1) <= c.max {
+		return c.Context.Done()
+	}
+
+	c.once.Do(func() { close(c.done) })
+	return c.done
+}
+
+func (c *stepContext) Err() error {
+	if atomic.LoadInt64(&c.n) > c.max {
+		return errStepLimit
+	}
+	return c.Context.Err()
+}
+
+// LimitError is the error for a script that exceeded one of its Limits.
+type LimitError struct {
+	// Limit is the name of the limit, one of the Limit constants.
+	Limit string
+	Max   int64
+	// Err is the error returned by the engine, if any.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("script exceeded limit %s of %d", e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// LimitExceeded returns an error of the kind errors.ResourceExhausted which
+// wraps a *LimitError. err is the error returned by the engine, if any.
+func LimitExceeded(limit string, max int64, err error) error {
+	const op = "engine.LimitExceeded"
+
+	return errors.E(
+		errors.WithOp(op), errors.ResourceExhausted,
+		errors.WithErr(&LimitError{Limit: limit, Max: max, Err: err}),
+	)
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// PanicError is the error for a panic in a script engine, or in encoding
+// the asset for it, which was recovered by the Transformer.
+type PanicError struct {
+	// Engine is the name of the engine.
+	Engine string
+	// URN of the asset being transformed.
+	URN string
+	// Value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in engine %s transforming %q: %v", e.Engine, e.URN, e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover recovers from a panic and sets *err to an error of the kind
+// errors.Internal which wraps a *PanicError. It must be deferred directly:
+//
+//	func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
+//		const op = "goja.Transform"
+//
+//		defer engine.Recover(op, "goja", a.GetUrn(), &err)
+//		...
+//	}
+func Recover(op, name, urn string, err *error) {
+	if r := recover(); r != nil {
+		*err = Recovered(op, name, urn, r)
+	}
+}
+
+// Recovered returns the error for the value r returned by recover. It is
+// meant for Transformers which handle some of the panics themselves.
+func Recovered(op, name, urn string, r interface{}) error {
+	return errors.E(
+		errors.WithOp(op), errors.Internal,
+		errors.WithErr(&PanicError{Engine: name, URN: urn, Value: r, Stack: debug.Stack()}),
+	)
+}
+
+// Trap records a panic in a Go function called by the script, such as the
+// URLer, for the engines which recover it themselves and report it as a
+// script error, losing its value and stack. A Trap is used for one run.
+type Trap struct {
+	op, name, urn string
+	err           error
+}
+
+// NewTrap returns a Trap for the run of the engine name on the asset with
+// the given URN.
+func NewTrap(op, name, urn string) *Trap {
+	return &Trap{op: op, name: name, urn: urn}
+}
+
+// URLer returns u wrapped so that a panic in it is recorded and propagated
+// to the engine as the error returned by Err, which go-lua requires of the
+// panics it recovers.
+func (t *Trap) URLer(u func(string) string) func(string) string {
+	return func(s string) string {
+		defer func() {
+			if r := recover(); r != nil {
+				if t.err == nil {
+					t.err = Recovered(t.op, t.name, t.urn, r)
+				}
+				panic(t.err)
+			}
+		}()
+		return u(s)
+	}
+}
+
+// Err returns the error for the first panic recorded by the Trap, as
+// returned by Recovered, or nil.
+func (t *Trap) Err() error {
+	return t.err
+}
@@ -0,0 +1,25 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Printer returns the writer to which the script prints, Options.Output or
+// os.Stderr if it is nil. stdout carries the assets in batch mode, so that
+// the script never prints to it by default.
+func (o Options) Printer() io.Writer {
+	if o.Output == nil {
+		return os.Stderr
+	}
+	return o.Output
+}
+
+// Println writes the arguments to w separated by sep and followed by a
+// newline, as print does in Lua with a tab and console.log in JavaScript
+// with a space.
+func Println(w io.Writer, sep string, args []string) {
+	fmt.Fprintln(w, strings.Join(args, sep))
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"io"
+	"os"
+
+	"github.com/sudo-suhas/xgo/errors"
+)
+
+// Script is the source of the script that is run by the Transformer.
+type Script interface {
+	Source() (string, error)
+}
+
+// ScriptString is a Script with the source specified inline.
+type ScriptString string
+
+// Source returns the script as is.
+func (s ScriptString) Source() (string, error) { return string(s), nil }
+
+// ScriptFile is a Script that is read from the file at the given path.
+type ScriptFile string
+
+// Source reads the script from the file.
+func (f ScriptFile) Source() (string, error) {
+	const op = "ScriptFile.Source"
+
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+
+	return string(b), nil
+}
+
+// ScriptReader returns a Script that is read from r. The reader is
+// consumed on the first call to Source.
+func ScriptReader(r io.Reader) Script {
+	return &readerScript{r: r}
+}
+
+type readerScript struct {
+	r   io.Reader
+	src *string
+}
+
+func (s *readerScript) Source() (string, error) {
+	const op = "ScriptReader.Source"
+
+	if s.src != nil {
+		return *s.src, nil
+	}
+
+	b, err := io.ReadAll(s.r)
+	if err != nil {
+		return "", errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	src := string(b)
+	s.src = &src
+	return src, nil
+}
+
+// ScriptSource returns the source of the script in the options. If the
+// options do not specify a script, the fallback is returned.
+func (o Options) ScriptSource(fallback string) (string, error) {
+	const op = "engine.ScriptSource"
+
+	if o.Script == nil {
+		return fallback, nil
+	}
+
+	src, err := o.Script.Source()
+	if err != nil {
+		return "", errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return src, nil
+}
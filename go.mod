@@ -8,16 +8,14 @@ require (
 	github.com/benthosdev/benthos/v4 v4.9.1
 	github.com/d5/tengo/v2 v2.13.0
 	github.com/dop251/goja v0.0.0-20221003171542-5ea1285e6c91
-	github.com/fatih/structs v1.1.0
 	github.com/itchyny/gojq v0.12.6
 	github.com/mattn/anko v0.1.9
-	github.com/mitchellh/mapstructure v1.5.0
 	github.com/robertkrimen/otto v0.0.0-20221011175642-09fc211e5ab1
 	github.com/sirupsen/logrus v1.9.0
-	github.com/spy16/pkg v0.2.4
 	github.com/sudo-suhas/xgo v0.2.0
 	github.com/yuin/gopher-lua v0.0.0-20200816102855-ee81675732da
 	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v3 v3.0.1
 	layeh.com/gopher-luar v1.0.8
 )
 
@@ -45,5 +43,4 @@ require (
 	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	gopkg.in/sourcemap.v1 v1.0.5 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
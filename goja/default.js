@@ -0,0 +1,30 @@
+asset.labels = Object.assign({ script_engine: 'goja' }, asset.labels);
+
+for (const e of asset.data.entities) {
+	e.labels = Object.assign({ catch_phrase: 'Say hello to my little friend.' }, e.labels);
+}
+
+for (const f of asset.data.features) {
+	switch (f.name) {
+		case 'ongoing_placed_and_waiting_acceptance_orders':
+		case 'ongoing_orders':
+			f.entity_name = 'customer_orders';
+			break;
+		case 'merchant_avg_dispatch_arrival_time_10m':
+			f.entity_name = 'merchant_driver';
+			break;
+		case 'ongoing_accepted_orders':
+			f.entity_name = 'merchant_orders';
+			break;
+	}
+}
+
+asset.owners = [{ name: 'Big Mom', email: 'big.mom@wholecakeisland.com' }].concat(asset.owners);
+
+asset.url = urler(asset.name);
+
+for (const u of asset.lineage.upstreams) {
+	if (u.service !== 'kafka') continue;
+
+	u.urn = u.urn.replace('.yonkou.io', '');
+}
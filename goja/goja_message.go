@@ -0,0 +1,614 @@
+package goja
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+// message returns the proto message as seen by the script: an object which
+// reads and writes the fields of the message through protoreflect, so that
+// compiled and dynamic messages are alike. Fields are named by their proto
+// names and only the populated ones are listed, as in JSON. The fields in
+// fixed are read as the given values instead and cannot be assigned, which
+// is how the asset exposes its unpacked data.
+//
+// A value assigned to a field is checked against the kind of the field.
+// Timestamps are Dates, enums the names of their values and bytes base64
+// strings. Repeated and map fields are arrays and objects backed by the
+// message. Reading an unset message, repeated or map field returns an
+// empty one which allocates the field when it is first written, so that
+// asset.lineage.upstreams can be assigned when the asset has no lineage.
+// Assigning a property which is not a field is ignored, or throws a
+// TypeError in strict mode.
+func (r *runtime) message(m proto.Message, fixed map[string]goja.Value) goja.Value {
+	return r.vm.NewDynamicObject(&messageObject{r: r, m: m.ProtoReflect(), fixed: fixed})
+}
+
+// object returns the object for the message, which is the same for each
+// read of the message during the run.
+func (r *runtime) object(m protoreflect.Message) goja.Value {
+	if o, ok := r.objects[m]; ok {
+		return o
+	}
+
+	o := r.vm.NewDynamicObject(&messageObject{r: r, m: m})
+	r.objects[m] = o
+	return o
+}
+
+// messageObject is a goja.DynamicObject over a message. The message of an
+// unset field is only allocated in the parent when it is written.
+type messageObject struct {
+	r *runtime
+	// m is nil until the field fd of the parent is populated.
+	m      protoreflect.Message
+	parent *messageObject
+	fd     protoreflect.FieldDescriptor
+
+	fixed map[string]goja.Value
+	// children holds the objects for the message, repeated and map fields
+	// read from the message, so that each read returns the same object.
+	children map[protoreflect.Name]goja.Value
+}
+
+// get returns the message for reading, which is read-only if it has not
+// been allocated.
+func (o *messageObject) get() protoreflect.Message {
+	if o.m != nil {
+		return o.m
+	}
+
+	pm := o.parent.get()
+	if pm.Has(o.fd) {
+		o.m = pm.Get(o.fd).Message()
+		return o.m
+	}
+	return pm.Get(o.fd).Message()
+}
+
+// mutable returns the message for writing, allocating it in the parent.
+func (o *messageObject) mutable() protoreflect.Message {
+	if o.m == nil {
+		o.m = o.parent.mutable().Mutable(o.fd).Message()
+	}
+	return o.m
+}
+
+// allocated returns the message if it is populated in the parent or nil.
+func (o *messageObject) allocated() protoreflect.Message {
+	if o.m == nil && o.parent.get().Has(o.fd) {
+		return o.get()
+	}
+	return o.m
+}
+
+func (o *messageObject) Get(key string) goja.Value {
+	if v, ok := o.fixed[key]; ok {
+		return v
+	}
+
+	pm := o.get()
+	fd := pm.Descriptor().Fields().ByName(protoreflect.Name(key))
+	switch {
+	case fd == nil:
+		return nil
+
+	case fd.IsList() || fd.IsMap() || fd.Message() != nil && !protoutil.IsTimestamp(fd):
+		if v, ok := o.children[fd.Name()]; ok {
+			return v
+		}
+
+		var v goja.Value
+		switch {
+		case fd.IsList():
+			v = o.r.vm.NewDynamicArray(&listArray{r: o.r, parent: o, fd: fd})
+		case fd.IsMap():
+			v = o.r.vm.NewDynamicObject(&mapObject{r: o.r, parent: o, fd: fd})
+		default:
+			v = o.r.vm.NewDynamicObject(&messageObject{r: o.r, parent: o, fd: fd})
+		}
+		if o.children == nil {
+			o.children = make(map[protoreflect.Name]goja.Value)
+		}
+		o.children[fd.Name()] = v
+		return v
+
+	case fd.Message() != nil && !pm.Has(fd):
+		return goja.Null()
+
+	default:
+		return o.r.toJS(fd, pm.Get(fd))
+	}
+}
+
+func (o *messageObject) Set(key string, val goja.Value) bool {
+	if _, ok := o.fixed[key]; ok {
+		panic(o.r.vm.NewTypeError("%s cannot be replaced, modify its fields instead", key))
+	}
+
+	desc := o.get().Descriptor()
+	fd := desc.Fields().ByName(protoreflect.Name(key))
+	if fd == nil {
+		if o.r.strict {
+			panic(o.r.vm.NewTypeError("unknown field %s of %s", key, desc.FullName()))
+		}
+		return false
+	}
+
+	if err := o.set(fd, val); err != nil {
+		panic(o.r.vm.NewTypeError(err.Error()))
+	}
+	return true
+}
+
+// set assigns the value to the field, or clears it if the value is null or
+// undefined.
+func (o *messageObject) set(fd protoreflect.FieldDescriptor, val goja.Value) error {
+	delete(o.children, fd.Name())
+
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		if pm := o.allocated(); pm != nil {
+			pm.Clear(fd)
+		}
+		return nil
+	}
+
+	pm := o.mutable()
+	var (
+		v   protoreflect.Value
+		err error
+	)
+	switch {
+	case fd.IsList():
+		v, err = o.r.toList(pm.NewField(fd).List(), fd, val)
+	case fd.IsMap():
+		v, err = o.r.toMap(pm.NewField(fd).Map(), fd, val)
+	default:
+		v, err = o.r.toProto(fd, val, func() protoreflect.Value { return pm.NewField(fd) })
+	}
+	if err != nil {
+		return err
+	}
+
+	pm.Set(fd, v)
+	return nil
+}
+
+func (o *messageObject) Has(key string) bool {
+	if _, ok := o.fixed[key]; ok {
+		return true
+	}
+	return o.get().Descriptor().Fields().ByName(protoreflect.Name(key)) != nil
+}
+
+func (o *messageObject) Delete(key string) bool {
+	if _, ok := o.fixed[key]; ok {
+		return false
+	}
+
+	fd := o.get().Descriptor().Fields().ByName(protoreflect.Name(key))
+	if fd == nil {
+		return true
+	}
+	delete(o.children, fd.Name())
+	if pm := o.allocated(); pm != nil {
+		pm.Clear(fd)
+	}
+	return true
+}
+
+func (o *messageObject) Keys() []string {
+	pm := o.get()
+	fields := pm.Descriptor().Fields()
+	keys := make([]string, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		name := string(fields.Get(i).Name())
+		if _, ok := o.fixed[name]; ok || pm.Has(fields.Get(i)) {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// listArray is a goja.DynamicArray over a repeated field.
+type listArray struct {
+	r      *runtime
+	parent *messageObject
+	fd     protoreflect.FieldDescriptor
+}
+
+func (a *listArray) list() protoreflect.List {
+	return a.parent.get().Get(a.fd).List()
+}
+
+func (a *listArray) mutable() protoreflect.List {
+	return a.parent.mutable().Mutable(a.fd).List()
+}
+
+func (a *listArray) Len() int { return a.list().Len() }
+
+func (a *listArray) Get(idx int) goja.Value {
+	l := a.list()
+	if idx < 0 || idx >= l.Len() {
+		return nil
+	}
+	return a.r.toJS(a.fd, l.Get(idx))
+}
+
+func (a *listArray) Set(idx int, val goja.Value) bool {
+	if idx < 0 {
+		return false
+	}
+
+	l := a.mutable()
+	v, err := a.r.toProto(a.fd, val, l.NewElement)
+	if err != nil {
+		panic(a.r.vm.NewTypeError("%s[%d]: %s", a.fd.Name(), idx, err))
+	}
+
+	for l.Len() <= idx {
+		l.Append(l.NewElement())
+	}
+	l.Set(idx, v)
+	return true
+}
+
+func (a *listArray) SetLen(n int) bool {
+	if n == a.Len() {
+		return true
+	}
+
+	l := a.mutable()
+	if n < l.Len() {
+		l.Truncate(n)
+	}
+	for l.Len() < n {
+		l.Append(l.NewElement())
+	}
+	return true
+}
+
+// mapObject is a goja.DynamicObject over a map field.
+type mapObject struct {
+	r      *runtime
+	parent *messageObject
+	fd     protoreflect.FieldDescriptor
+}
+
+func (o *mapObject) get() protoreflect.Map {
+	return o.parent.get().Get(o.fd).Map()
+}
+
+func (o *mapObject) mutable() protoreflect.Map {
+	return o.parent.mutable().Mutable(o.fd).Map()
+}
+
+func (o *mapObject) Get(key string) goja.Value {
+	k, err := protoutil.MapKey(o.fd.MapKey(), key)
+	if err != nil {
+		return nil
+	}
+
+	m := o.get()
+	if !m.Has(k) {
+		return nil
+	}
+	return o.r.toJS(o.fd.MapValue(), m.Get(k))
+}
+
+func (o *mapObject) Set(key string, val goja.Value) bool {
+	k, err := protoutil.MapKey(o.fd.MapKey(), key)
+	if err != nil {
+		panic(o.r.vm.NewTypeError("%s[%q]: %s", o.fd.Name(), key, err))
+	}
+
+	if goja.IsUndefined(val) || goja.IsNull(val) {
+		return o.Delete(key)
+	}
+
+	m := o.mutable()
+	v, err := o.r.toProto(o.fd.MapValue(), val, m.NewValue)
+	if err != nil {
+		panic(o.r.vm.NewTypeError("%s[%q]: %s", o.fd.Name(), key, err))
+	}
+	m.Set(k, v)
+	return true
+}
+
+func (o *mapObject) Has(key string) bool {
+	k, err := protoutil.MapKey(o.fd.MapKey(), key)
+	return err == nil && o.get().Has(k)
+}
+
+func (o *mapObject) Delete(key string) bool {
+	k, err := protoutil.MapKey(o.fd.MapKey(), key)
+	if err == nil && o.get().Has(k) {
+		o.mutable().Clear(k)
+	}
+	return true
+}
+
+func (o *mapObject) Keys() []string {
+	m := o.get()
+	keys := make([]string, 0, m.Len())
+	m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k.String())
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+// toJS returns the value of a field, or of an element of it, as seen by
+// the script.
+func (r *runtime) toJS(fd protoreflect.FieldDescriptor, v protoreflect.Value) goja.Value {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if protoutil.IsTimestamp(fd) {
+			return r.date(protoutil.TimeOf(v.Message()))
+		}
+		return r.object(v.Message())
+
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return r.vm.ToValue(string(ev.Name()))
+		}
+		return r.vm.ToValue(int64(v.Enum()))
+
+	case protoreflect.BytesKind:
+		return r.vm.ToValue(base64.StdEncoding.EncodeToString(v.Bytes()))
+
+	default:
+		return r.vm.ToValue(v.Interface())
+	}
+}
+
+// toProto converts the value assigned by the script to a field, or to an
+// element of it, as per the kind of the field. newMessage returns the
+// message into which the fields of an object are set.
+func (r *runtime) toProto(fd protoreflect.FieldDescriptor, val goja.Value, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	if val == nil {
+		val = goja.Undefined()
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := val.Export().(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := integer(fd, val, math.MinInt32, math.MaxInt32)
+		return protoreflect.ValueOfInt32(int32(i)), err
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := integer(fd, val, math.MinInt64, math.MaxInt64)
+		return protoreflect.ValueOfInt64(i), err
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		i, err := integer(fd, val, 0, math.MaxUint32)
+		return protoreflect.ValueOfUint32(uint32(i)), err
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// A number above 2^63 - 1 is a float64 which cannot hold the
+		// integer exactly, so it is rejected rather than rounded.
+		if f, ok := val.Export().(float64); ok && f >= math.MaxInt64 {
+			return protoreflect.Value{}, fmt.Errorf("%s: %.0f is above 2^63 - 1, the largest %s a script can set", fd.FullName(), f, fd.Kind())
+		}
+		i, err := integer(fd, val, 0, math.MaxInt64)
+		return protoreflect.ValueOfUint64(uint64(i)), err
+
+	case protoreflect.FloatKind:
+		if f, ok := number(val); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+
+	case protoreflect.DoubleKind:
+		if f, ok := number(val); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+
+	case protoreflect.StringKind:
+		if s, ok := val.Export().(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+
+	case protoreflect.BytesKind:
+		if s, ok := val.Export().(string); ok {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return protoreflect.Value{}, fmt.Errorf("%s must be a base64 string: %w", fd.FullName(), err)
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+
+	case protoreflect.EnumKind:
+		if s, ok := val.Export().(string); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+			return protoreflect.Value{}, fmt.Errorf("%s: unknown value %q of %s", fd.FullName(), s, fd.Enum().FullName())
+		}
+		i, err := integer(fd, val, math.MinInt32, math.MaxInt32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), err
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return r.toMessage(fd, val, newMessage)
+	}
+
+	return protoreflect.Value{}, mismatch(fd, val)
+}
+
+func (r *runtime) toMessage(fd protoreflect.FieldDescriptor, val goja.Value, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	if protoutil.IsTimestamp(fd) {
+		t, ok := val.Export().(time.Time)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("%s must be a Date, got %s", fd.FullName(), typeOf(val))
+		}
+		v := newMessage()
+		protoutil.SetTime(v.Message(), t)
+		return v, nil
+	}
+
+	if o, ok := val.Export().(*messageObject); ok {
+		if got, want := o.get().Descriptor().FullName(), fd.Message().FullName(); got != want {
+			return protoreflect.Value{}, fmt.Errorf("%s must be a %s, got %s", fd.FullName(), want, got)
+		}
+		if pm := o.allocated(); pm != nil {
+			return protoreflect.ValueOfMessage(pm), nil
+		}
+		return newMessage(), nil
+	}
+
+	obj, ok := val.(*goja.Object)
+	if !ok || obj.ClassName() == "Array" {
+		return protoreflect.Value{}, mismatch(fd, val)
+	}
+
+	v := newMessage()
+	dst := &messageObject{r: r, m: v.Message()}
+	fields := fd.Message().Fields()
+	for _, k := range obj.Keys() {
+		efd := fields.ByName(protoreflect.Name(k))
+		if efd == nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown field %s of %s", k, fd.Message().FullName())
+		}
+		if err := dst.set(efd, obj.Get(k)); err != nil {
+			return protoreflect.Value{}, err
+		}
+	}
+	return v, nil
+}
+
+// toList converts the array assigned by the script to a repeated field.
+func (r *runtime) toList(l protoreflect.List, fd protoreflect.FieldDescriptor, val goja.Value) (protoreflect.Value, error) {
+	obj, ok := val.(*goja.Object)
+	if !ok || obj.ClassName() != "Array" {
+		return protoreflect.Value{}, fmt.Errorf("%s must be an array, got %s", fd.FullName(), typeOf(val))
+	}
+
+	n := obj.Get("length").ToInteger()
+	for i := int64(0); i < n; i++ {
+		v, err := r.toProto(fd, obj.Get(strconv.FormatInt(i, 10)), l.NewElement)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("[%d]: %w", i, err)
+		}
+		l.Append(v)
+	}
+	return protoreflect.ValueOfList(l), nil
+}
+
+// toMap converts the object assigned by the script to a map field.
+func (r *runtime) toMap(m protoreflect.Map, fd protoreflect.FieldDescriptor, val goja.Value) (protoreflect.Value, error) {
+	obj, ok := val.(*goja.Object)
+	if !ok || obj.ClassName() == "Array" {
+		return protoreflect.Value{}, fmt.Errorf("%s must be an object, got %s", fd.FullName(), typeOf(val))
+	}
+
+	for _, key := range obj.Keys() {
+		k, err := protoutil.MapKey(fd.MapKey(), key)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%s[%q]: %w", fd.FullName(), key, err)
+		}
+		v, err := r.toProto(fd.MapValue(), obj.Get(key), m.NewValue)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%s[%q]: %w", fd.FullName(), key, err)
+		}
+		m.Set(k, v)
+	}
+	return protoreflect.ValueOfMap(m), nil
+}
+
+// integer returns the number assigned to the field if it is an integer
+// between min and max.
+func integer(fd protoreflect.FieldDescriptor, val goja.Value, min, max int64) (int64, error) {
+	var i int64
+	switch v := val.Export().(type) {
+	case int64:
+		i = v
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%s must be an integer, got %v", fd.FullName(), v)
+		}
+		if v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%s: %.0f overflows %s", fd.FullName(), v, fd.Kind())
+		}
+		i = int64(v)
+	default:
+		return 0, mismatch(fd, val)
+	}
+
+	if i < min || i > max {
+		return 0, fmt.Errorf("%s: %d overflows %s", fd.FullName(), i, fd.Kind())
+	}
+	return i, nil
+}
+
+func number(val goja.Value) (float64, bool) {
+	switch v := val.Export().(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func mismatch(fd protoreflect.FieldDescriptor, val goja.Value) error {
+	want := fd.Kind().String()
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		want = "object"
+	case protoreflect.EnumKind:
+		want = "enum name"
+	case protoreflect.BytesKind:
+		want = "base64 string"
+	}
+	return fmt.Errorf("%s must be a %s, got %s", fd.FullName(), want, typeOf(val))
+}
+
+// typeOf returns the type of the value as given by typeof, with null and
+// arrays told apart.
+func typeOf(val goja.Value) string {
+	switch {
+	case val == nil || goja.IsUndefined(val):
+		return "undefined"
+	case goja.IsNull(val):
+		return "null"
+	}
+
+	if obj, ok := val.(*goja.Object); ok {
+		if obj.ClassName() == "Array" {
+			return "array"
+		}
+		return "object"
+	}
+
+	switch val.Export().(type) {
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int64, float64:
+		return "number"
+	}
+	return val.String()
+}
+
+func (r *runtime) date(t time.Time) goja.Value {
+	d, err := r.vm.New(r.vm.Get("Date"), r.vm.ToValue(t.UnixMilli()))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
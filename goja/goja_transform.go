@@ -2,82 +2,233 @@ package goja
 
 import (
 	"context"
+	_ "embed"
+	"sync"
 
 	"github.com/dop251/goja"
 	"github.com/sudo-suhas/xgo/errors"
-	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
 )
 
-var script = `
-asset.labels = Object.assign({ script_engine: 'goja' }, asset.labels);
+//go:embed default.js
+var defaultScript string
 
-for (const e of data.entities) {
-	e.labels = Object.assign({ catch_phrase: 'Say hello to my little friend.' }, e.labels);
+func init() {
+	engine.Register("goja", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
 }
 
-for (const f of data.features) {
-	switch (f.name) {
-		case 'ongoing_placed_and_waiting_acceptance_orders':
-		case 'ongoing_orders':
-			f.entity_name = 'customer_orders';
-			break;
-		case 'merchant_avg_dispatch_arrival_time_10m':
-			f.entity_name = 'merchant_driver';
-			break;
-		case 'ongoing_accepted_orders':
-			f.entity_name = 'merchant_orders';
-			break;
-	}
+// Transformer is safe for concurrent use. Each call to T runs the script
+// in a runtime taken from a pool. The script is run inside a function so
+// that its declarations do not outlive the run and the global object is
+// reset before the runtime is returned to the pool. The built-in objects,
+// such as Object.prototype and Math, are frozen so that a run cannot
+// change them for the next: writes to them are ignored, or throw in
+// strict mode.
+type Transformer struct {
+	urler   func(string) string
+	program *goja.Program
+	limits  engine.Limits
+	strict  bool
+	pool    sync.Pool
 }
 
-asset.owners = [{ name: 'Big Mom', email: 'big.mom@wholecakeisland.com' }].concat(asset.owners);
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "goja.New"
 
-asset.url = urler(asset.name);
+	if err := opts.Limits.Supported("goja", engine.LimitMaxCallDepth); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-for (const u of asset.lineage.upstreams) {
-	if (u.service !== 'kafka') continue;
-	
-	u.urn = u.urn.replace('.yonkou.io', '');
-}
-`
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-type Transformer struct {
-	URLer func(string) string
+	program, err := goja.Compile("script.js", "(function () {"+script+"\n})();", opts.Strict)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("compile js script"), errors.WithErr(err))
+	}
+
+	t := Transformer{urler: opts.URLer, program: program, limits: opts.Limits, strict: opts.Strict}
+	rt, err := t.newRuntime()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	t.pool.Put(rt)
+
+	return &t, nil
 }
 
-func (t *Transformer) T(_ context.Context, a *asset.Asset) error {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "goja.Transform"
 
+	defer engine.Recover(op, "goja", a.GetUrn(), &err)
+
+	a, commit := engine.Stage(a)
+
+	if err := engine.ContextError(ctx); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
 	data, err := a.Data.UnmarshalNew()
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	rt, err := t.runtime()
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := rt.vm.Set("asset", rt.message(a, map[string]goja.Value{"data": rt.message(data, nil)})); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	stop := engine.Interrupt(ctx, func() { rt.vm.Interrupt(ctx.Err()) })
+	// A panic out of a Go function called by the script unwinds through
+	// RunProgram, which skips the call to stop below.
+	defer stop()
+	_, runErr := rt.vm.RunProgram(t.program)
+	stop()
+	rt.vm.ClearInterrupt()
+
+	if err := rt.reset(); err == nil {
+		t.pool.Put(rt)
+	}
+	if err := engine.ContextError(ctx); err != nil && runErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	var overflow *goja.StackOverflowError
+	if t.limits.MaxCallDepth != 0 && errors.As(runErr, &overflow) {
+		return errors.E(errors.WithOp(op), errors.WithErr(
+			engine.LimitExceeded(engine.LimitMaxCallDepth, int64(t.limits.MaxCallDepth), runErr),
+		))
+	}
+	if runErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("execute js script"), errors.WithErr(runErr))
+	}
+
+	if err := engine.PackData(a, data); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := t.limits.CheckOutput(a); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	commit()
+
+	return nil
+}
+
+func (t *Transformer) runtime() (*runtime, error) {
+	if rt, ok := t.pool.Get().(*runtime); ok {
+		return rt, nil
+	}
+
+	return t.newRuntime()
+}
+
+func (t *Transformer) newRuntime() (*runtime, error) {
+	const op = "goja.newRuntime"
+
 	vm := goja.New()
-	vm.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
-
-	for name, v := range map[string]interface{}{
-		"asset": a,
-		"data":  data,
-		"urler": func(call goja.FunctionCall) goja.Value {
-			url := t.URLer(call.Argument(0).String())
-			return vm.ToValue(url)
-		},
-	} {
-		if err := vm.Set(name, v); err != nil {
-			return errors.E(errors.WithOp(op), errors.WithErr(err))
+	if t.limits.MaxCallDepth != 0 {
+		vm.SetMaxCallStackSize(t.limits.MaxCallDepth)
+	}
+
+	if err := vm.Set("urler", func(call goja.FunctionCall) goja.Value {
+		url := t.urler(call.Argument(0).String())
+		return vm.ToValue(url)
+	}); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if _, err := vm.RunString(freezeBuiltins); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("freeze built-ins"), errors.WithErr(err))
+	}
+
+	global := vm.GlobalObject()
+	globals := make(map[string]goja.Value)
+	for _, k := range global.Keys() {
+		globals[k] = global.Get(k)
+	}
+
+	return &runtime{
+		vm:      vm,
+		globals: globals,
+		strict:  t.strict,
+		objects: make(map[protoreflect.Message]goja.Value),
+	}, nil
+}
+
+// freezeBuiltins freezes the values of the properties of the global object
+// and everything reachable from them, including prototypes, but not the
+// global object itself, which is reset instead.
+const freezeBuiltins = `(function () {
+	var seen = new Set([globalThis]);
+	function freeze(o) {
+		if (o === null || o === undefined || (typeof o !== "object" && typeof o !== "function") || seen.has(o)) {
+			return;
 		}
+		seen.add(o);
+		Object.freeze(o);
+		Reflect.ownKeys(o).forEach(function (k) {
+			var d = Object.getOwnPropertyDescriptor(o, k);
+			freeze(d.value);
+			freeze(d.get);
+			freeze(d.set);
+		});
+		freeze(Object.getPrototypeOf(o));
 	}
+	Reflect.ownKeys(globalThis).forEach(function (k) {
+		freeze(Object.getOwnPropertyDescriptor(globalThis, k).value);
+	});
+})();`
+
+// runtime is a goja runtime with the globals required by the script.
+type runtime struct {
+	vm *goja.Runtime
+	// globals holds the properties of the global object after the runtime
+	// was initialised.
+	globals map[string]goja.Value
+	// strict is set if the script is run in strict mode, see
+	// engine.Options.Strict.
+	strict bool
+	// objects holds the objects for the messages read by the script in
+	// the run, see runtime.object.
+	objects map[protoreflect.Message]goja.Value
+}
 
-	if _, err := vm.RunString(script); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("execute js script"), errors.WithErr(err))
+// reset restores the global object to its state after initialisation.
+func (r *runtime) reset() error {
+	for m := range r.objects {
+		delete(r.objects, m)
 	}
 
-	a.Data, err = anypb.New(data)
-	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	global := r.vm.GlobalObject()
+	for _, k := range global.Keys() {
+		if _, ok := r.globals[k]; ok {
+			continue
+		}
+		if err := global.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	for k, v := range r.globals {
+		if err := global.Set(k, v); err != nil {
+			return err
+		}
 	}
 
 	return nil
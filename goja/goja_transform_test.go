@@ -0,0 +1,114 @@
+package goja_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/goja"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// leakScript reports what it sees of the changes it makes to the global
+// object and the built-ins, which must not be seen by the next run.
+const leakScript = `
+asset.description = [typeof leak, typeof Object.prototype.leak, typeof Math.leak, typeof [].leak].join(",");
+leak = "leaked";
+Object.prototype.leak = "leaked";
+Math.leak = "leaked";
+Array.prototype.leak = "leaked";
+`
+
+func TestTransformerReset(t *testing.T) {
+	tr, err := goja.New(engine.Options{Script: engine.ScriptString(leakScript)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		a, err := sample.FeatureTable()
+		if err != nil {
+			t.Fatalf("sample.FeatureTable() error = %v", err)
+		}
+
+		if err := tr.T(context.Background(), a); err != nil {
+			t.Fatalf("run %d: T() error = %v", i, err)
+		}
+
+		if want := "undefined,undefined,undefined,undefined"; a.Description != want {
+			t.Errorf("run %d: description = %q, want %q", i, a.Description, want)
+		}
+	}
+}
+
+func TestTransformerResetStrict(t *testing.T) {
+	tr, err := goja.New(engine.Options{
+		Script: engine.ScriptString(`Object.prototype.leak = "leaked";`),
+		Strict: true,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	err = tr.T(context.Background(), a)
+	if err == nil || !strings.Contains(err.Error(), "leak") {
+		t.Errorf("T() error = %v, want an error writing leak", err)
+	}
+}
+
+func TestTransformerUint64(t *testing.T) {
+	cases := []struct {
+		script string
+		want   uint64
+		err    string
+	}{
+		{script: `asset.data.value = 9007199254740992;`, want: 1 << 53},
+		{script: `asset.data.value = 18446744073709551615;`, err: "TypeError: google.protobuf.UInt64Value.value: 18446744073709551616 is above 2^63 - 1"},
+		{script: `asset.data.value = 9223372036854775808;`, err: "is above 2^63 - 1"},
+		{script: `asset.data.value = -1;`, err: "TypeError: google.protobuf.UInt64Value.value: -1 overflows uint64"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.script, func(t *testing.T) {
+			tr, err := goja.New(engine.Options{Script: engine.ScriptString(c.script)})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			data, err := anypb.New(wrapperspb.UInt64(1))
+			if err != nil {
+				t.Fatalf("anypb.New() error = %v", err)
+			}
+			a := &asset.Asset{Urn: "urn:uint64", Data: data}
+
+			err = tr.T(context.Background(), a)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("T() error = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+
+			var got wrapperspb.UInt64Value
+			if err := a.Data.UnmarshalTo(&got); err != nil {
+				t.Fatalf("UnmarshalTo() error = %v", err)
+			}
+			if got.Value != c.want {
+				t.Errorf("T() value = %d, want %d", got.Value, c.want)
+			}
+		})
+	}
+}
@@ -2,95 +2,226 @@ package gojq
 
 import (
 	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/itchyny/gojq"
 	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
 	"github.com/sudo-suhas/play-script-engine/structmap"
 )
 
-var script = `
-.labels.script_engine = "gojq" | 
+//go:embed default.jq
+var defaultScript string
 
-.data.entities[].labels.catch_phrase = "Go ahead. Make my day." |
+func init() {
+	engine.Register("gojq", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
 
-.data.features[] |= 
-	if .name == "ongoing_placed_and_waiting_acceptance_orders" or .name == "ongoing_orders" then 
-		.entity_name = "customer_orders" 
-	elif .name == "merchant_avg_dispatch_arrival_time_10m" then 
-		.entity_name = "merchant_driver"
-	elif .name == "ongoing_accepted_orders" then 
-		.entity_name = "merchant_orders"
-	else . end |
+// Transformer is safe for concurrent use. gojq modifies the constants of
+// a compiled query while running it, so each run takes a compiled query
+// of its own from a pool, compiling another if the pool is empty.
+//
+// Each output of the query is an asset. T expects exactly one, while
+// Expand returns every output if engine.Options.FanOut is set. No output,
+// such as from empty, drops the asset.
+type Transformer struct {
+	compile func() (*gojq.Code, error)
+	codes   sync.Pool
+	limits  engine.Limits
+	fanOut  bool
+}
 
-.owners += [{name: "Big Mom", email: "big.mom@wholecakeisland.com"}] |
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "gojq.New"
 
-.url = urler(.name) |
+	if err := opts.Limits.Supported("gojq", engine.LimitMaxSteps); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-.lineage.upstreams[] |=
-	if .service == "kafka" then .urn = (.urn | sub("\\.yonkou\\.io"; ""))  
-	else . end
-`
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-type Transformer struct {
-	URLer func(string) string
+	query, err := gojq.Parse(script)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("parse query"), errors.WithErr(parseError(script, err)))
+	}
+
+	compile := func() (*gojq.Code, error) {
+		return gojq.Compile(
+			query,
+			gojq.WithFunction(
+				"urler", 1, 1,
+				func(jqCtx interface{}, args []interface{}) interface{} {
+					s, ok := args[0].(string)
+					if !ok {
+						return errors.E(errors.WithOp("gojq.urler"), errors.WithTextf("unexpected type: %T", args[0]))
+					}
+
+					return opts.URLer(s)
+				},
+			),
+		)
+	}
+
+	code, err := compile()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("compile query"), errors.WithErr(err))
+	}
+
+	t := Transformer{compile: compile, limits: opts.Limits, fanOut: opts.FanOut}
+	t.codes.Put(code)
+	return &t, nil
 }
 
-func (t *Transformer) T(ctx context.Context, a *asset.Asset) error {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "gojq.Transform"
 
-	query, err := gojq.Parse(script)
-	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("parse query"), errors.WithErr(err))
-	}
-
-	code, err := gojq.Compile(
-		query,
-		gojq.WithFunction(
-			"urler", 1, 1,
-			func(jqCtx interface{}, args []interface{}) interface{} {
-				s, ok := args[0].(string)
-				if !ok {
-					return errors.E(errors.WithOp("gojq.urler"), errors.WithTextf("unexpected type: %T", s))
-				}
-
-				return t.URLer(s)
-			},
-		),
-	)
+	defer engine.Recover(op, "gojq", a.GetUrn(), &err)
+
+	res, err := t.run(ctx, a, false)
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("compile query"), errors.WithErr(err))
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	if len(res) == 0 {
+		return errors.E(errors.WithOp(op), errors.WithErr(&engine.DroppedError{URN: a.GetUrn()}))
+	}
+
+	proto.Reset(a)
+	proto.Merge(a, res[0])
+
+	return nil
+}
+
+func (t *Transformer) Expand(ctx context.Context, a *asset.Asset) (res []*asset.Asset, err error) {
+	const op = "gojq.Expand"
+
+	defer engine.Recover(op, "gojq", a.GetUrn(), &err)
+
+	if res, err = t.run(ctx, a, t.fanOut); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	return res, nil
+}
+
+// run runs the query against the asset and decodes each of its outputs
+// into a new asset, leaving the asset unchanged. More than one output is
+// an error unless fanOut is set.
+func (t *Transformer) run(ctx context.Context, a *asset.Asset, fanOut bool) ([]*asset.Asset, error) {
 	wrapper, err := structmap.NewAssetWrapper(a)
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
+		return nil, err
 	}
 
-	m, err := wrapper.EncodeWithoutTypes()
+	m, err := wrapper.Encode(structmap.Format{Time: structmap.TimeRFC3339, BigInt: true})
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
+		return nil, err
 	}
 
-	iter := code.RunWithContext(ctx, m)
-	v, ok := iter.Next()
+	code, ok := t.codes.Get().(*gojq.Code)
 	if !ok {
-		return errors.E(errors.WithOp(op), errors.WithText("unexpected result"), errors.WithErr(err))
+		if code, err = t.compile(); err != nil {
+			return nil, errors.E(errors.WithText("compile query"), errors.WithErr(err))
+		}
+	}
+	// The outputs may share values with the constants of the query, so it
+	// is returned to the pool only once they have been decoded.
+	defer t.codes.Put(code)
+
+	// gojq checks the context before each instruction, which counts the
+	// steps.
+	runCtx, stepsExceeded := ctx, func() bool { return false }
+	if t.limits.MaxSteps != 0 {
+		runCtx, stepsExceeded = engine.CountSteps(ctx, t.limits.MaxSteps)
 	}
 
-	switch v := v.(type) {
-	case error:
-		return errors.E(errors.WithOp(op), errors.WithText("run query"), errors.WithErr(err))
+	var res []*asset.Asset
+	iter := code.RunWithContext(runCtx, m)
+	for i := 0; ; i++ {
+		v, ok := iter.Next()
+		if !ok {
+			return res, nil
+		}
 
-	case map[string]interface{}:
-		if err := wrapper.OverwriteWith(v); err != nil {
-			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		if qErr, ok := v.(error); ok {
+			if stepsExceeded() {
+				return nil, engine.LimitExceeded(engine.LimitMaxSteps, t.limits.MaxSteps, qErr)
+			}
+			if err := engine.ContextError(ctx); err != nil {
+				return nil, err
+			}
+			// gojq does not keep the positions of the query in the
+			// compiled code, nor in its errors.
+			return nil, errors.E(errors.WithText("run query (gojq gives no position)"), errors.WithErr(qErr))
 		}
 
-	default:
-		return errors.E(errors.WithOp(op), errors.WithTextf("unexpected result: %T", v), errors.WithErr(err))
+		if i == 1 && !fanOut {
+			return nil, errors.E(errors.InvalidInput, errors.WithErr(&engine.MultipleAssetsError{URN: a.GetUrn()}))
+		}
+
+		out, err := t.decode(a, v)
+		if err != nil {
+			return nil, errors.E(errors.WithTextf("output %d", i), errors.WithErr(err))
+		}
+		res = append(res, out)
 	}
+}
 
-	return nil
+// decode returns the asset for an output of the query, with the data of
+// the same type as that of the input asset a.
+func (t *Transformer) decode(a *asset.Asset, v interface{}) (*asset.Asset, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.E(errors.WithTextf("unexpected result: %T", v))
+	}
+
+	out := &asset.Asset{Data: a.Data}
+	wrapper, err := structmap.NewAssetWrapper(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wrapper.OverwriteWith(m); err != nil {
+		return nil, err
+	}
+
+	if err := t.limits.CheckOutput(out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// parseError adds the line and column of the token at which the query
+// failed to parse to the error.
+func parseError(script string, err error) error {
+	tErr, ok := err.(interface{ Token() (string, int) })
+	if !ok {
+		return err
+	}
+
+	// The offset is the position of the start of the token, counted
+	// from 1.
+	_, offset := tErr.Token()
+	if offset--; offset < 0 || offset > len(script) {
+		offset = len(script)
+	}
+	before := script[:offset]
+	line := strings.Count(before, "\n") + 1
+	col := offset - strings.LastIndex(before, "\n")
+	return fmt.Errorf("%d:%d: %w", line, col, err)
 }
@@ -0,0 +1,374 @@
+package gojq_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/gojq"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/protoset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// TestTransformerConcurrent is meant to be run with -race, gojq modifies
+// the constants of a compiled query, such as the owner added by the
+// default script, while running it.
+func TestTransformerConcurrent(t *testing.T) {
+	tr, err := gojq.New(engine.Options{URLer: func(s string) string { return "https://example.com/" + s }})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	want := proto.Clone(a).(*asset.Asset)
+	if err := tr.T(context.Background(), want); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	const goroutines, runs = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < runs; j++ {
+				got := proto.Clone(a).(*asset.Asset)
+				if err := tr.T(context.Background(), got); err != nil {
+					errs <- err
+					return
+				}
+				if !equal(got, want) {
+					t.Errorf("T() got = %v, want %v", got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("T() error = %v", err)
+	}
+}
+
+// TestTransformerURLerType calls urler with a number, which is rejected
+// with an error naming its type.
+func TestTransformerURLerType(t *testing.T) {
+	tr, err := gojq.New(engine.Options{
+		Script: engine.ScriptString(`.url = urler(42)`),
+		URLer:  func(s string) string { return s },
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	err = tr.T(context.Background(), a)
+	if want := "unexpected type: int"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("T() error = %v, want %q", err, want)
+	}
+}
+
+// TestTransformerIntegers checks that the integers of the data keep their
+// precision, beyond the 2^53 of a float64 and up to the largest uint64.
+func TestTransformerIntegers(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		data   proto.Message
+		want   proto.Message
+		err    string
+	}{
+		{
+			name:   "int64/unchanged",
+			script: `.`,
+			data:   wrapperspb.Int64(9007199254740993),
+			want:   wrapperspb.Int64(9007199254740993),
+		},
+		{
+			name:   "int64/arithmetic",
+			script: `.data.value += 2`,
+			data:   wrapperspb.Int64(9007199254740993),
+			want:   wrapperspb.Int64(9007199254740995),
+		},
+		{
+			name:   "int64/literal",
+			script: `.data.value = 9223372036854775807`,
+			data:   wrapperspb.Int64(0),
+			want:   wrapperspb.Int64(math.MaxInt64),
+		},
+		{
+			name:   "int64/overflow",
+			script: `.data.value = 9223372036854775808`,
+			data:   wrapperspb.Int64(0),
+			err:    "data.value: 9223372036854775808 is out of range",
+		},
+		{
+			name:   "uint64/unchanged",
+			script: `.`,
+			data:   wrapperspb.UInt64(math.MaxUint64),
+			want:   wrapperspb.UInt64(math.MaxUint64),
+		},
+		{
+			name:   "uint64/arithmetic",
+			script: `.data.value -= 1`,
+			data:   wrapperspb.UInt64(math.MaxUint64),
+			want:   wrapperspb.UInt64(math.MaxUint64 - 1),
+		},
+		{
+			name:   "uint64/above int64",
+			script: `.data.value = 9223372036854775808`,
+			data:   wrapperspb.UInt64(0),
+			want:   wrapperspb.UInt64(1 << 63),
+		},
+		{
+			name:   "uint64/overflow",
+			script: `.data.value += 1`,
+			data:   wrapperspb.UInt64(math.MaxUint64),
+			err:    "data.value: 18446744073709551616 is out of range",
+		},
+		{
+			name:   "uint64/negative",
+			script: `.data.value = -1`,
+			data:   wrapperspb.UInt64(0),
+			err:    "data.value: -1 is out of range",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			tr, err := gojq.New(engine.Options{Script: engine.ScriptString(c.script)})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			data, err := anypb.New(c.data)
+			if err != nil {
+				t.Fatalf("anypb.New() error = %v", err)
+			}
+			a := &asset.Asset{Urn: "urn:integers", Data: data}
+
+			err = tr.T(context.Background(), a)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("T() error = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+
+			got, err := a.Data.UnmarshalNew()
+			if err != nil {
+				t.Fatalf("UnmarshalNew() error = %v", err)
+			}
+			if !proto.Equal(got, c.want) {
+				t.Errorf("T() data = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestTransformerEnum checks that an enum is the name of its value, in the
+// data of a type registered by protoset, and can be set by name or number.
+func TestTransformerEnum(t *testing.T) {
+	if err := protoset.Register("../sample/testdata/model.binpb"); err != nil {
+		t.Fatalf("protoset.Register() error = %v", err)
+	}
+
+	cases := []struct {
+		script string
+		want   string
+		// desc is the description, set by the script to the flavor read.
+		desc string
+		err  string
+	}{
+		{script: `.description = .data.flavor`, want: "FLAVOR_SKLEARN", desc: "FLAVOR_SKLEARN"},
+		{script: `.data.flavor = "FLAVOR_PYTORCH"`, want: "FLAVOR_PYTORCH"},
+		{script: `.data.flavor = 2`, want: "FLAVOR_TENSORFLOW"},
+		{script: `.data.flavor = "FLAVOR_ONNX"`, err: "data.flavor"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.script, func(t *testing.T) {
+			tr, err := gojq.New(engine.Options{Script: engine.ScriptString(c.script)})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			a, err := assetio.ReadFile("../sample/testdata/model.json", assetio.FormatJSON)
+			if err != nil {
+				t.Fatalf("assetio.ReadFile() error = %v", err)
+			}
+
+			err = tr.T(context.Background(), a)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("T() error = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+
+			data, err := a.Data.UnmarshalNew()
+			if err != nil {
+				t.Fatalf("UnmarshalNew() error = %v", err)
+			}
+			pm := data.ProtoReflect()
+			fd := pm.Descriptor().Fields().ByName("flavor")
+			if got := fd.Enum().Values().ByNumber(pm.Get(fd).Enum()).Name(); string(got) != c.want {
+				t.Errorf("T() flavor = %s, want %s", got, c.want)
+			}
+			if a.Description != c.desc {
+				t.Errorf("T() description = %q, want %q", a.Description, c.desc)
+			}
+		})
+	}
+}
+
+// TestTransformerOutputs checks the assets returned by Expand and T for
+// the outputs of the query.
+func TestTransformerOutputs(t *testing.T) {
+	split := `.labels = {} | (.name |= . + "-a"), (.name |= . + "-b")`
+	multiple := "script produced more than one asset"
+
+	cases := []struct {
+		name   string
+		script string
+		fanOut bool
+		// names of the assets returned by Expand, the first of which is
+		// that of the asset transformed by T if there is only one.
+		names []string
+		// expandErr and tErr are the errors returned by Expand and T.
+		expandErr, tErr string
+	}{
+		{name: "one", script: `.name |= . + "-a"`, names: []string{"{name}-a"}},
+		{name: "fan out", script: split, fanOut: true, names: []string{"{name}-a", "{name}-b"}, tErr: multiple},
+		{name: "multiple", script: split, expandErr: multiple, tErr: multiple},
+		{name: "empty", script: `empty`},
+		{name: "select", script: `select(.name == "none")`},
+		{name: "error", script: `error("boom")`, expandErr: "run query (gojq gives no position): error: boom", tErr: "run query (gojq gives no position): error: boom"},
+		{name: "error after output", script: `., error("boom")`, fanOut: true, expandErr: "run query (gojq gives no position): error: boom", tErr: "run query (gojq gives no position): error: boom"},
+		{name: "type error", script: `.name + 1`, expandErr: "run query (gojq gives no position): cannot add: string", tErr: "run query (gojq gives no position): cannot add: string"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			tr, err := gojq.New(engine.Options{Script: engine.ScriptString(c.script), FanOut: c.fanOut})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			in, err := sample.FeatureTable()
+			if err != nil {
+				t.Fatalf("sample.FeatureTable() error = %v", err)
+			}
+			var names []string
+			for _, n := range c.names {
+				names = append(names, strings.ReplaceAll(n, "{name}", in.Name))
+			}
+
+			a := proto.Clone(in).(*asset.Asset)
+			got, err := tr.Expand(context.Background(), a)
+			checkOutputErr(t, "Expand", err, c.expandErr)
+			if c.expandErr == "" {
+				if len(got) != len(names) {
+					t.Fatalf("Expand() returned %d assets, want %d", len(got), len(names))
+				}
+				for i, g := range got {
+					if g.Name != names[i] {
+						t.Errorf("Expand() asset %d name = %q, want %q", i, g.Name, names[i])
+					}
+				}
+			}
+			if !proto.Equal(a, in) {
+				t.Errorf("Expand() changed the asset to %v", a)
+			}
+
+			err = tr.T(context.Background(), a)
+			switch {
+			case c.tErr != "":
+				checkOutputErr(t, "T", err, c.tErr)
+				if !proto.Equal(a, in) {
+					t.Errorf("T() changed the asset to %v", a)
+				}
+
+			case len(names) == 0:
+				var dErr *engine.DroppedError
+				if !errors.As(err, &dErr) || dErr.URN != in.Urn {
+					t.Errorf("T() error = %v, want *engine.DroppedError for %s", err, in.Urn)
+				}
+
+			case err != nil:
+				t.Errorf("T() error = %v", err)
+
+			case a.Name != names[0]:
+				t.Errorf("T() name = %q, want %q", a.Name, names[0])
+			}
+		})
+	}
+}
+
+// checkOutputErr checks that the error of the method contains want, and is
+// a *engine.MultipleAssetsError if it is about multiple assets.
+func checkOutputErr(t *testing.T, method string, err error, want string) {
+	t.Helper()
+
+	if want == "" {
+		if err != nil {
+			t.Errorf("%s() error = %v", method, err)
+		}
+		return
+	}
+
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("%s() error = %v, want %q", method, err, want)
+	}
+	var mErr *engine.MultipleAssetsError
+	if strings.Contains(want, "more than one asset") && !errors.As(err, &mErr) {
+		t.Errorf("%s() error = %v, want *engine.MultipleAssetsError", method, err)
+	}
+}
+
+// equal compares the assets and their unpacked data, the bytes of the
+// packed data differ with the order in which the labels are marshalled.
+func equal(got, want *asset.Asset) bool {
+	gotData, err := got.GetData().UnmarshalNew()
+	if err != nil {
+		return false
+	}
+	wantData, err := want.GetData().UnmarshalNew()
+	if err != nil {
+		return false
+	}
+	if !proto.Equal(gotData, wantData) {
+		return false
+	}
+
+	got, want = proto.Clone(got).(*asset.Asset), proto.Clone(want).(*asset.Asset)
+	got.Data, want.Data = nil, nil
+	return proto.Equal(got, want)
+}
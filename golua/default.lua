@@ -0,0 +1,38 @@
+local strings = require("goluago/strings")
+
+if asset.labels == nil then
+	asset.labels = {}
+end
+asset.labels["script_engine"] = "golua"
+
+for _, e in ipairs(asset.data.entities or {}) do
+	if e.labels == nil then
+		e.labels = {}
+	end
+	e.labels["catch_phrase"] = "Here’s Johnny!"
+end
+
+for _, f in ipairs(asset.data.features or {}) do
+	if f.name == "ongoing_placed_and_waiting_acceptance_orders" or f.name == "ongoing_orders" then
+		f.entity_name = "customer_orders"
+	elseif f.name == "merchant_avg_dispatch_arrival_time_10m" then
+		f.entity_name = "merchant_driver"
+	elseif f.name == "ongoing_accepted_orders" then
+		f.entity_name = "merchant_orders"
+	end
+end
+
+if asset.owners == nil then
+	-- An empty table is decoded as an empty list but one with elements
+	-- can only be pulled from Lua if it is marked with array.
+	asset.owners = array({})
+end
+asset.owners[#asset.owners + 1] = {name = "Big Mom", email = "big.mom@wholecakeisland.com"}
+
+asset.url = urler(asset.name)
+
+for _, u in ipairs((asset.lineage or {}).upstreams or {}) do
+	if u.service == "kafka" then
+		u.urn = strings.replace(u.urn, ".yonkou.io", "", -1)
+	end
+end
@@ -1,63 +1,175 @@
 package golua
 
 import (
+	"bytes"
 	"context"
+	_ "embed"
+	"io"
+	"sync"
 
 	"github.com/Shopify/go-lua"
+	goluagostrings "github.com/Shopify/goluago/pkg/strings"
 	luautil "github.com/Shopify/goluago/util"
 	"github.com/sudo-suhas/xgo/errors"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
 	"github.com/sudo-suhas/play-script-engine/structmap"
 )
 
-var script = `
-if asset.labels == nil then
-	asset.labels = {}
-end
-asset.labels["script_engine"] = "gopherlua"
+//go:embed default.lua
+var defaultScript string
 
-for _, e in ipairs(asset.data.entities) do
-	if e.labels == nil then
-		e.labels = {}
-	end
-	e.labels["catch_phrase"] = "Here’s Johnny!"
-end
+func init() {
+	engine.Register("golua", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
 
-for _, f in ipairs(asset.data.features) do
-	if f.name == "ongoing_placed_and_waiting_acceptance_orders" or f.name == "ongoing_orders" then
-		f.entity_name = "customer_orders"
-	elseif f.name == "merchant_avg_dispatch_arrival_time_10m" then
-		f.entity_name = "merchant_driver"
-	elseif f.name == "ongoing_accepted_orders" then
-		f.entity_name = "merchant_orders"
-	end
-end
+// hookCount is the number of instructions after which the hook checks the
+// context and the step limit while the script runs.
+const hookCount = 1000
 
-if asset.owners == nil then
-	-- asset.owners gets initialised as a map and decoding into *asset.Asset fails.
-	-- asset.owners = {}
-end
--- Inserting into the table fails with runtime error: invalid key to 'next'
--- table.insert(asset.owners, {name = "Big Mom", email = "big.mom@wholecakeisland.com"})
+// Registry keys for the compiled script and the function which resets the
+// globals.
+const (
+	scriptKey = "play_script_engine.script"
+	resetKey  = "play_script_engine.reset"
+)
 
-asset.url = urler(asset.name)
+// Transformer is safe for concurrent use. Each call to T runs the script
+// in a lua state taken from a pool. The globals of the state, and the
+// tables reachable from them such as the string library, are reset before
+// it is returned to the pool.
+type Transformer struct {
+	urler func(string) string
+	// chunk is the compiled script as a binary chunk.
+	chunk  []byte
+	limits engine.Limits
+	// out receives what the script prints.
+	out  io.Writer
+	pool sync.Pool
+}
 
-for _, u in ipairs(asset.lineage.upstreams) do
-	if u.service == "kafka" then
-		-- Fails inexplicably with "attempt to call a nil value" runtime error.
-		-- u.urn = u.urn:gsub(".yonkou.io", "") 
-	end
-end
-`
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "golua.New"
 
-type Transformer struct {
-	URLer func(string) string
+	if err := opts.Limits.Supported("golua", engine.LimitMaxSteps, engine.LimitMaxCallDepth); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	l := lua.NewState()
+	if err := lua.LoadBuffer(l, script+"\npull_table(asset)", "script.lua", "t"); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("compile lua script"), errors.WithErr(err))
+	}
+
+	var chunk bytes.Buffer
+	if err := l.Dump(&chunk); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("dump lua script"), errors.WithErr(err))
+	}
+
+	t := Transformer{urler: opts.URLer, chunk: chunk.Bytes(), limits: opts.Limits, out: opts.Printer()}
+	st, err := t.newState()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	t.pool.Put(st)
+
+	return &t, nil
 }
 
-func (t *Transformer) T(_ context.Context, a *asset.Asset) error {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "golua.Transform"
 
+	defer engine.Recover(op, "golua", a.GetUrn(), &err)
+
+	a, commit := engine.Stage(a)
+
+	if err := engine.ContextError(ctx); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	wrapper, err := structmap.NewAssetWrapper(a)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	m, err := wrapper.Encode(structmap.Format{Time: structmap.TimeUnix})
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	st, err := t.state()
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	l := st.l
+	luautil.DeepPush(l, m)
+	l.SetGlobal("asset")
+
+	st.setHook(ctx, t.limits)
+
+	st.trap = engine.NewTrap(op, "golua", a.GetUrn())
+	l.Field(lua.RegistryIndex, scriptKey)
+	runErr := l.ProtectedCall(0, 0, 0)
+	v, pullErr, stopErr, trapErr := st.result, st.pullErr, st.stopErr, st.trap.Err()
+
+	if err := st.reset(); err == nil {
+		t.pool.Put(st)
+	}
+
+	if trapErr != nil {
+		return trapErr
+	}
+	if stopErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(stopErr))
+	}
+	if runErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("execute lua script"), errors.WithErr(runErr))
+	}
+	if pullErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("execute lua script"), errors.WithErr(pullErr))
+	}
+
+	res, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.E(errors.WithOp(op), errors.WithTextf("unexpected result: %T", v))
+	}
+
+	if err := wrapper.OverwriteWith(res); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := t.limits.CheckOutput(a); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	commit()
+
+	return nil
+}
+
+func (t *Transformer) state() (*state, error) {
+	if st, ok := t.pool.Get().(*state); ok {
+		return st, nil
+	}
+
+	return t.newState()
+}
+
+func (t *Transformer) newState() (*state, error) {
+	const op = "golua.newState"
+
 	l := lua.NewState()
 	libs := []lua.RegistryFunction{
 		{Name: "_G", Function: lua.BaseOpen},
@@ -69,45 +181,161 @@ func (t *Transformer) T(_ context.Context, a *asset.Asset) error {
 	for _, lib := range libs {
 		lua.Require(l, lib.Name, lib.Function, true)
 	}
+	goluagostrings.Open(l)
+	luautil.Open(l)
+	l.SetTop(0)
 
-	wrapper, err := structmap.NewAssetWrapper(a)
-	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
-	}
-
-	luautil.DeepPush(l, wrapper.Encode())
-	l.SetGlobal("asset")
+	st := state{l: l}
 
 	l.Register("urler", func(l *lua.State) int {
-		u := t.URLer(lua.CheckString(l, 1))
+		u := st.trap.URLer(t.urler)(lua.CheckString(l, 1))
 		l.PushString(u)
 		return 1
 	})
-
-	var (
-		v       interface{}
-		pullErr error
-	)
+	l.Register("print", func(l *lua.State) int {
+		args := make([]string, l.Top())
+		for i := range args {
+			args[i], _ = lua.ToStringMeta(l, i+1)
+			l.Pop(1)
+		}
+		engine.Println(t.out, "\t", args)
+		return 0
+	})
 	l.Register("pull_table", func(l *lua.State) int {
-		v, pullErr = luautil.PullTable(l, 1)
+		st.result, st.pullErr = luautil.PullTable(l, 1)
 		return 0
 	})
 
-	if err := lua.DoString(l, script+"\npull_table(asset)"); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("execute lua script"), errors.WithErr(err))
+	if err := l.Load(bytes.NewReader(t.chunk), "script.lua", "b"); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("load lua script"), errors.WithErr(err))
 	}
-	if pullErr != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("execute lua script"), errors.WithErr(err))
+	l.SetField(lua.RegistryIndex, scriptKey)
+
+	if err := lua.LoadString(l, snapshotTables); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("load snapshot"), errors.WithErr(err))
+	}
+	if err := l.ProtectedCall(0, 1, 0); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("snapshot globals"), errors.WithErr(err))
 	}
+	l.SetField(lua.RegistryIndex, resetKey)
 
-	res, ok := v.(map[string]interface{})
-	if !ok {
-		return errors.E(errors.WithOp(op), errors.WithTextf("unexpected result: %T", v), errors.WithErr(err))
+	return &st, nil
+}
+
+// snapshotTables snapshots the global table, the metatable of strings and
+// the tables reachable from them, such as the libraries and their
+// metatables, and returns the function which restores them. The functions
+// it uses are kept as upvalues so that the script cannot change them.
+const snapshotTables = `
+local next, rawget, rawset, type, getmetatable, setmetatable = next, rawget, rawset, type, getmetatable, setmetatable
+
+local snapshots = {}
+local function walk(v)
+	if type(v) ~= "table" or snapshots[v] then
+		return
+	end
+	local s = {fields = {}, metatable = getmetatable(v)}
+	snapshots[v] = s
+	for k, val in next, v do
+		s.fields[k] = val
+		walk(k)
+		walk(val)
+	end
+	walk(s.metatable)
+end
+walk(_G)
+walk(getmetatable(""))
+
+return function()
+	for t, s in next, snapshots do
+		-- Collect the keys which are not in the snapshot first, clearing a
+		-- key during the traversal breaks it.
+		local added, n = {}, 0
+		for k in next, t do
+			if rawget(s.fields, k) == nil then
+				n = n + 1
+				added[n] = k
+			end
+		end
+		for i = 1, n do
+			rawset(t, added[i], nil)
+		end
+		for k, v in next, s.fields do
+			rawset(t, k, v)
+		end
+		setmetatable(t, s.metatable)
+	end
+end
+`
+
+// state is a lua state with the globals required by the script.
+type state struct {
+	l *lua.State
+
+	// result and pullErr are set by pull_table at the end of the script.
+	result  interface{}
+	pullErr error
+
+	// stopErr is set by the hook when it stops the script.
+	stopErr error
+
+	// trap records a panic in the URLer, which go-lua turns into an error
+	// of the script.
+	trap *engine.Trap
+}
+
+// setHook sets a hook which stops the script once the context is done or
+// the script exceeds the step or call depth limit. The hook records the
+// cause in stopErr before raising an error in the script. Checking every
+// hookCount instructions keeps the overhead low while still stopping a
+// loop promptly. The call depth is checked by the same count hook as the
+// call hook of go-lua corrupts the call stack.
+func (st *state) setHook(ctx context.Context, limits engine.Limits) {
+	if ctx.Done() == nil && limits.MaxSteps == 0 && limits.MaxCallDepth == 0 {
+		return
 	}
 
-	if err := wrapper.OverwriteWith(res); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	count := int64(hookCount)
+	if limits.MaxSteps != 0 && limits.MaxSteps < count {
+		count = limits.MaxSteps
+	}
+	if limits.MaxCallDepth != 0 && int64(limits.MaxCallDepth) < count {
+		count = int64(limits.MaxCallDepth)
 	}
 
-	return nil
+	var steps int64
+	lua.SetDebugHook(st.l, func(l *lua.State, _ lua.Debug) {
+		steps += count
+		switch {
+		case ctx.Err() != nil:
+			st.stopErr = engine.ContextError(ctx)
+
+		case limits.MaxSteps != 0 && steps >= limits.MaxSteps:
+			st.stopErr = engine.LimitExceeded(engine.LimitMaxSteps, limits.MaxSteps, nil)
+
+		case limits.MaxCallDepth != 0:
+			if _, ok := lua.Stack(l, limits.MaxCallDepth); ok {
+				st.stopErr = engine.LimitExceeded(engine.LimitMaxCallDepth, int64(limits.MaxCallDepth), nil)
+			}
+		}
+		if st.stopErr != nil {
+			lua.Errorf(l, "%s", st.stopErr)
+		}
+	}, lua.MaskCount, int(count))
+}
+
+// reset restores the global variables, and the tables reachable from
+// them, to their values after initialisation and clears the stack and the
+// result. The state must not be reused if it fails, such as when the
+// script protects the metatable of a library.
+func (st *state) reset() error {
+	l := st.l
+	l.SetTop(0)
+	lua.SetDebugHook(l, nil, 0, 0)
+	st.result, st.pullErr, st.stopErr, st.trap = nil, nil, nil, nil
+
+	l.Field(lua.RegistryIndex, resetKey)
+	err := l.ProtectedCall(0, 0, 0)
+	l.SetTop(0)
+	return err
 }
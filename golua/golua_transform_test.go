@@ -0,0 +1,43 @@
+package golua_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/golua"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// leakScript reports what it sees of the changes it makes to the globals
+// and the libraries, which must not be seen by the next run.
+const leakScript = `
+asset.description = table.concat({type(leak), type(string.leak), type(math.leak), type(("").leak), type(getmetatable(string))}, ",")
+leak = "leaked"
+string.leak = "leaked"
+math.leak = "leaked"
+getmetatable("").__index = {leak = "leaked"}
+setmetatable(string, {})
+`
+
+func TestTransformerReset(t *testing.T) {
+	tr, err := golua.New(engine.Options{Script: engine.ScriptString(leakScript)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		a, err := sample.FeatureTable()
+		if err != nil {
+			t.Fatalf("sample.FeatureTable() error = %v", err)
+		}
+
+		if err := tr.T(context.Background(), a); err != nil {
+			t.Fatalf("run %d: T() error = %v", i, err)
+		}
+
+		if want := "nil,nil,nil,nil,nil"; a.Description != want {
+			t.Errorf("run %d: description = %q, want %q", i, a.Description, want)
+		}
+	}
+}
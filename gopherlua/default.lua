@@ -0,0 +1,42 @@
+-- each iterates over the elements of a slice, which is nil if empty.
+local function each(s)
+	if s == nil then
+		return function() end
+	end
+	return s()
+end
+
+if asset.labels == nil then
+	asset.labels = {}
+end
+asset.labels["script_engine"] = "gopherlua"
+
+for _, e in each(asset.data.entities) do
+	if e.labels == nil then
+		e.labels = {}
+	end
+	e.labels["catch_phrase"] = "You Shall Not Pass!"
+end
+
+for _, f in each(asset.data.features) do
+	if f.name == "ongoing_placed_and_waiting_acceptance_orders" or f.name == "ongoing_orders" then
+		f.entity_name = "customer_orders"
+	elseif f.name == "merchant_avg_dispatch_arrival_time_10m" then
+		f.entity_name = "merchant_driver"
+	elseif f.name == "ongoing_accepted_orders" then
+		f.entity_name = "merchant_orders"
+	end
+end
+
+if asset.owners == nil then
+	asset.owners = {}
+end
+asset.owners = asset.owners + {name = "Big Mom", email = "big.mom@wholecakeisland.com"}
+
+asset.url = urler(asset.name)
+
+for _, u in each(asset.lineage and asset.lineage.upstreams) do
+	if u.service == "kafka" then
+		u.urn = u.urn:gsub("\.yonkou\.io", "")
+	end
+end
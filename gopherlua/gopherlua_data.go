@@ -0,0 +1,146 @@
+package gopherlua
+
+import (
+	lua "github.com/yuin/gopher-lua"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+	luar "layeh.com/gopher-luar"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/structmap"
+)
+
+// exposeData patches the luar metatable of the asset so that its data
+// field is read as the message unpacked from it, which T sets in st.data
+// for each run. The field cannot be assigned as the message is packed into
+// the asset once the script has run.
+func (st *state) exposeData() {
+	L := st.L
+
+	mt := luar.MT(L, &asset.Asset{})
+	index, newIndex := mt.RawGetString("__index"), mt.RawGetString("__newindex")
+	mt.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		if L.Get(2).String() == "data" {
+			L.Push(st.data)
+			return 1
+		}
+
+		L.Push(index)
+		L.Push(L.Get(1))
+		L.Push(L.Get(2))
+		L.Call(2, 1)
+		return 1
+	}))
+	mt.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		if L.Get(2).String() == "data" {
+			L.ArgError(2, L.Get(2).String()+" cannot be replaced, modify its fields instead")
+		}
+
+		L.Push(newIndex)
+		L.Push(L.Get(1))
+		L.Push(L.Get(2))
+		L.Push(L.Get(3))
+		L.Call(3, 0)
+		return 0
+	}))
+}
+
+// setData sets the data of the asset as seen by the script and returns a
+// function which must be called once the script has run, before the state
+// is reset. A dynamic message, of a type registered by protoset, has no Go
+// struct to reflect on. It is a table in the form returned by
+// structmap.Encode instead, with timestamps as numbers of seconds, which
+// decode sets back into the message.
+func (st *state) setData(m proto.Message) (decode func() error, err error) {
+	if _, ok := m.(*dynamicpb.Message); !ok {
+		st.data = luar.New(st.L, m)
+		exposeTimes(st.L, m)
+		return func() error { return nil }, nil
+	}
+
+	enc, err := structmap.Encode(m, structmap.Format{Time: structmap.TimeUnix})
+	if err != nil {
+		return nil, err
+	}
+
+	st.data = toLua(st.L, enc)
+	data := st.data
+	return func() error { return structmap.Decode(fromLua(data), m) }, nil
+}
+
+// toLua converts the value in the form returned by structmap.Encode into
+// tables.
+func toLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for k, e := range v {
+			t.RawSetString(k, toLua(L, e))
+		}
+		return t
+
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for i, e := range v {
+			t.RawSetInt(i+1, toLua(L, e))
+		}
+		return t
+
+	case string:
+		return lua.LString(v)
+
+	case bool:
+		return lua.LBool(v)
+
+	case int:
+		return lua.LNumber(v)
+
+	case float64:
+		return lua.LNumber(v)
+
+	default:
+		return luar.New(L, v)
+	}
+}
+
+// fromLua is the inverse of toLua. A table is a list if its keys are the
+// integers from 1 to its length and a map otherwise, so an empty table is
+// an empty map, which structmap.Decode accepts for a list.
+func fromLua(v lua.LValue) interface{} {
+	switch v := v.(type) {
+	case *lua.LTable:
+		n, keys := v.MaxN(), 0
+		v.ForEach(func(lua.LValue, lua.LValue) { keys++ })
+		if n != 0 && n == keys {
+			l := make([]interface{}, n)
+			for i := range l {
+				l[i] = fromLua(v.RawGetInt(i + 1))
+			}
+			return l
+		}
+
+		m := make(map[string]interface{}, keys)
+		v.ForEach(func(k, e lua.LValue) {
+			m[k.String()] = fromLua(e)
+		})
+		return m
+
+	case lua.LString:
+		return string(v)
+
+	case lua.LBool:
+		return bool(v)
+
+	case lua.LNumber:
+		return float64(v)
+
+	case *lua.LNilType:
+		return nil
+
+	case *lua.LUserData:
+		return v.Value
+
+	default:
+		return v
+	}
+}
@@ -0,0 +1,108 @@
+package gopherlua
+
+import (
+	lua "github.com/yuin/gopher-lua"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	luar "layeh.com/gopher-luar"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+// timesKey marks a metatable patched by exposeTimes.
+const timesKey = "timestamps"
+
+// exposeTimes patches the luar metatables of the message and the messages
+// nested in it, so that their timestamp fields are read and written as
+// the number of seconds since the Unix epoch, as returned by os.time. The
+// metatables are cached in the state, so a type is patched only once.
+func exposeTimes(L *lua.LState, m proto.Message) {
+	exposeTimesOf(L, m.ProtoReflect(), make(map[protoreflect.FullName]bool))
+}
+
+func exposeTimesOf(L *lua.LState, pm protoreflect.Message, seen map[protoreflect.FullName]bool) {
+	desc := pm.Descriptor()
+	if seen[desc.FullName()] {
+		return
+	}
+	seen[desc.FullName()] = true
+
+	times := make(map[string]protoreflect.FieldDescriptor)
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.Message() == nil:
+		case protoutil.IsTimestamp(fd) && !fd.IsList():
+			times[string(fd.Name())] = fd
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				exposeTimesOf(L, pm.NewField(fd).Map().NewValue().Message(), seen)
+			}
+		case fd.IsList():
+			exposeTimesOf(L, pm.NewField(fd).List().NewElement().Message(), seen)
+		default:
+			exposeTimesOf(L, pm.NewField(fd).Message(), seen)
+		}
+	}
+	if len(times) == 0 {
+		return
+	}
+
+	mt := luar.MT(L, pm.Interface())
+	if mt.RawGetString(timesKey) != lua.LNil {
+		return
+	}
+	mt.RawSetString(timesKey, lua.LTrue)
+
+	index, newIndex := mt.RawGetString("__index"), mt.RawGetString("__newindex")
+	mt.RawSetString("__index", L.NewFunction(func(L *lua.LState) int {
+		fd, ok := times[L.Get(2).String()]
+		if !ok {
+			L.Push(index)
+			L.Push(L.Get(1))
+			L.Push(L.Get(2))
+			L.Call(2, 1)
+			return 1
+		}
+
+		pm := message(L)
+		if !pm.Has(fd) {
+			L.Push(lua.LNil)
+			return 1
+		}
+		t := pm.Get(fd).Message().Interface().(*timestamppb.Timestamp).AsTime()
+		L.Push(lua.LNumber(protoutil.UnixSeconds(t)))
+		return 1
+	}))
+	mt.RawSetString("__newindex", L.NewFunction(func(L *lua.LState) int {
+		fd, ok := times[L.Get(2).String()]
+		if !ok {
+			L.Push(newIndex)
+			L.Push(L.Get(1))
+			L.Push(L.Get(2))
+			L.Push(L.Get(3))
+			L.Call(3, 0)
+			return 0
+		}
+
+		pm := message(L)
+		switch v := L.Get(3).(type) {
+		case *lua.LNilType:
+			pm.Clear(fd)
+		case lua.LNumber:
+			t := protoutil.FromUnixSeconds(float64(v))
+			pm.Set(fd, protoreflect.ValueOfMessage(timestamppb.New(t).ProtoReflect()))
+		default:
+			L.ArgError(3, string(fd.Name())+" must be a number of seconds, got "+v.Type().String())
+		}
+		return 0
+	}))
+}
+
+// message returns the proto message of the userdata the metamethod was
+// called on.
+func message(L *lua.LState) protoreflect.Message {
+	return L.CheckUserData(1).Value.(proto.Message).ProtoReflect()
+}
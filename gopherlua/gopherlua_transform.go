@@ -2,89 +2,274 @@ package gopherlua
 
 import (
 	"context"
+	_ "embed"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
 
-	"github.com/spy16/pkg/lua"
 	"github.com/sudo-suhas/xgo/errors"
-	luastd "github.com/yuin/gopher-lua"
-	"google.golang.org/protobuf/types/known/anypb"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
 	luar "layeh.com/gopher-luar"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
 )
 
-var script = `
-if asset.labels == nil then
-	asset.labels = {}
-end
-asset.labels["script_engine"] = "gopherlua"
-
-for _, e in data.entities() do
-	if e.labels == nil then
-		e.labels = {}
-	end
-	e.labels["catch_phrase"] = "You Shall Not Pass!"
-end
-
-for _, f in data.features() do
-	if f.name == "ongoing_placed_and_waiting_acceptance_orders" or f.name == "ongoing_orders" then
-		f.entityName = "customer_orders"
-	elseif f.name == "merchant_avg_dispatch_arrival_time_10m" then
-		f.entityName = "merchant_driver"
-	elseif f.name == "ongoing_accepted_orders" then
-		f.entityName = "merchant_orders"
-	end
-end
-
-if asset.owners == nil then
-	asset.owners = {}
-end
-asset.owners = asset.owners + {Name = "Big Mom", Email = "big.mom@wholecakeisland.com"}
-
-asset.url = urler(asset.name)
-
-for _, u in asset.lineage.upstreams() do
-	if u.service == "kafka" then
-		u.urn = u.urn:gsub("\.yonkou\.io", "") 
-	end
-end
-`
+//go:embed default.lua
+var defaultScript string
 
+func init() {
+	engine.Register("gopherlua", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// Transformer is safe for concurrent use. Each call to T runs the script
+// in a lua state taken from a pool. The globals of the state, and the
+// tables reachable from them such as the string library, are reset before
+// it is returned to the pool.
 type Transformer struct {
-	URLer func(string) string
+	urler  func(string) string
+	proto  *lua.FunctionProto
+	limits engine.Limits
+	// out receives what the script prints.
+	out  io.Writer
+	pool sync.Pool
+}
+
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "gopherlua.New"
+
+	if err := opts.Limits.Supported("gopherlua", engine.LimitMaxSteps, engine.LimitMaxCallDepth); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(script), "script.lua")
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("parse lua script"), errors.WithErr(err))
+	}
+
+	proto, err := lua.Compile(chunk, "script.lua")
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("compile lua script"), errors.WithErr(err))
+	}
+
+	t := Transformer{urler: opts.URLer, proto: proto, limits: opts.Limits, out: opts.Printer()}
+	t.pool.Put(t.newState())
+
+	return &t, nil
 }
 
-func (t *Transformer) T(ctx context.Context, a *asset.Asset) error {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "gopherlua.Transform"
 
+	defer engine.Recover(op, "gopherlua", a.GetUrn(), &err)
+
+	a, commit := engine.Stage(a)
+
 	data, err := a.Data.UnmarshalNew()
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	l, err := lua.New(
-		lua.Context(ctx),
-		lua.Globals(map[string]interface{}{
-			"asset": a,
-			"data":  data,
-			"urler": func(L *luar.LState) int {
-				u := t.URLer(L.CheckString(1))
-				L.Push(luastd.LString(u))
-				return 1
-			},
-		}),
-	)
+	st := t.state()
+
+	// gopher-lua checks the context before each instruction, which counts
+	// the steps.
+	runCtx, stepsExceeded := ctx, func() bool { return false }
+	if t.limits.MaxSteps != 0 {
+		runCtx, stepsExceeded = engine.CountSteps(ctx, t.limits.MaxSteps)
+	}
+
+	L := st.L
+	L.SetContext(runCtx)
+	decodeData, err := st.setData(data)
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("init new lua state"), errors.WithErr(err))
+		st.reset()
+		t.pool.Put(st)
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
+	L.SetGlobal("asset", luar.New(L, a))
+	exposeTimes(L, a)
 
-	if err := l.Execute(script); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("execute lua script"), errors.WithErr(err))
+	st.trap = engine.NewTrap(op, "gopherlua", a.GetUrn())
+	L.Push(L.NewFunctionFromProto(t.proto))
+	runErr := L.PCall(0, lua.MultRet, nil)
+	trapErr := st.trap.Err()
+	var decodeErr error
+	if runErr == nil {
+		decodeErr = decodeData()
 	}
 
-	a.Data, err = anypb.New(data)
-	if err != nil {
+	st.reset()
+	t.pool.Put(st)
+
+	if trapErr != nil {
+		return trapErr
+	}
+	if err := engine.ContextError(ctx); err != nil && runErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	if runErr != nil && stepsExceeded() {
+		return errors.E(errors.WithOp(op), errors.WithErr(
+			engine.LimitExceeded(engine.LimitMaxSteps, t.limits.MaxSteps, runErr),
+		))
+	}
+	if t.limits.MaxCallDepth != 0 && runErr != nil && strings.Contains(runErr.Error(), "stack overflow") {
+		return errors.E(errors.WithOp(op), errors.WithErr(
+			engine.LimitExceeded(engine.LimitMaxCallDepth, int64(t.limits.MaxCallDepth), runErr),
+		))
+	}
+	if runErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("execute lua script"), errors.WithErr(runErr))
+	}
+	if decodeErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("decode data"), errors.WithErr(decodeErr))
+	}
+
+	if err := engine.PackData(a, data); err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	if err := t.limits.CheckOutput(a); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	commit()
+
+	return nil
+}
+
+func (t *Transformer) state() *state {
+	if st, ok := t.pool.Get().(*state); ok {
+		return st
+	}
+
+	return t.newState()
+}
+
+func (t *Transformer) newState() *state {
+	var opts lua.Options
+	if t.limits.MaxCallDepth != 0 {
+		opts.CallStackSize = t.limits.MaxCallDepth
+	}
+
+	L := lua.NewState(opts)
+	luar.GetConfig(L).FieldNames = fieldNames
+	st := &state{L: L, data: lua.LNil}
+	L.SetGlobal("urler", L.NewFunction(func(L *lua.LState) int {
+		u := st.trap.URLer(t.urler)(L.CheckString(1))
+		L.Push(lua.LString(u))
+		return 1
+	}))
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		args := make([]string, L.GetTop())
+		for i := range args {
+			args[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		engine.Println(t.out, "\t", args)
+		return 0
+	}))
+
+	st.tables = snapshotTables(L)
+	st.exposeData()
+	return st
+}
+
+// fieldNames names the fields of proto messages by their proto names, as
+// given by engine.FieldName, instead of the Go names of luar.
+func fieldNames(_ reflect.Type, f reflect.StructField) []string {
+	if name := engine.FieldName(f); name != "" {
+		return []string{name}
+	}
 	return nil
 }
+
+// state is a lua state with the globals required by the script.
+type state struct {
+	L *lua.LState
+	// tables holds the contents of the global table, and of the tables
+	// reachable from it, after the state was initialised.
+	tables map[*lua.LTable]snapshot
+	// data is the unpacked data of the asset being transformed, which the
+	// script reads as asset.data.
+	data lua.LValue
+	// trap records a panic in the URLer, which gopher-lua turns into an
+	// error of the script.
+	trap *engine.Trap
+}
+
+// reset restores the global variables, and the tables reachable from
+// them, to their contents after initialisation and clears the stack and
+// context.
+func (st *state) reset() {
+	for t, s := range st.tables {
+		var added []lua.LValue
+		t.ForEach(func(k, _ lua.LValue) {
+			if _, ok := s.fields[k]; !ok {
+				added = append(added, k)
+			}
+		})
+		for _, k := range added {
+			t.RawSet(k, lua.LNil)
+		}
+
+		for k, v := range s.fields {
+			t.RawSet(k, v)
+		}
+		t.Metatable = s.metatable
+	}
+
+	st.data = lua.LNil
+	st.trap = nil
+	st.L.SetTop(0)
+	st.L.RemoveContext()
+}
+
+// snapshot holds the contents and the metatable of a table.
+type snapshot struct {
+	fields    map[lua.LValue]lua.LValue
+	metatable lua.LValue
+}
+
+// snapshotTables returns the snapshots of the global table, the metatable
+// of strings and the tables reachable from them, such as the libraries
+// and their metatables.
+func snapshotTables(L *lua.LState) map[*lua.LTable]snapshot {
+	tables := make(map[*lua.LTable]snapshot)
+
+	var walk func(v lua.LValue)
+	walk = func(v lua.LValue) {
+		t, ok := v.(*lua.LTable)
+		if !ok {
+			return
+		}
+		if _, ok := tables[t]; ok {
+			return
+		}
+
+		s := snapshot{fields: make(map[lua.LValue]lua.LValue), metatable: t.Metatable}
+		tables[t] = s
+		t.ForEach(func(k, v lua.LValue) {
+			s.fields[k] = v
+			walk(k)
+			walk(v)
+		})
+		walk(t.Metatable)
+	}
+	walk(L.G.Global)
+	walk(L.GetMetatable(lua.LString("")))
+
+	return tables
+}
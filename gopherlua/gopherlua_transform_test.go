@@ -0,0 +1,85 @@
+package gopherlua_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/gopherlua"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// leakScript reports what it sees of the changes it makes to the globals
+// and the libraries, which must not be seen by the next run.
+const leakScript = `
+asset.description = table.concat({
+	tostring(leak), tostring(string.leak), tostring(("").leak),
+	tostring(getmetatable("").leak), tostring(table.leak),
+}, ",")
+leak = "leaked"
+string.leak = "leaked"
+getmetatable("").leak = "leaked"
+table.leak = "leaked"
+`
+
+func TestTransformerReset(t *testing.T) {
+	tr, err := gopherlua.New(engine.Options{Script: engine.ScriptString(leakScript)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		a, err := sample.FeatureTable()
+		if err != nil {
+			t.Fatalf("sample.FeatureTable() error = %v", err)
+		}
+
+		if err := tr.T(context.Background(), a); err != nil {
+			t.Fatalf("run %d: T() error = %v", i, err)
+		}
+
+		if want := "nil,nil,nil,nil,nil"; a.Description != want {
+			t.Errorf("run %d: description = %q, want %q", i, a.Description, want)
+		}
+	}
+}
+
+// TestTransformerTime checks that a timestamp read as a number of seconds
+// and written back keeps the microseconds, as structmap does for go-lua.
+// The nanoseconds of the sample data are rounded.
+func TestTransformerTime(t *testing.T) {
+	tr, err := gopherlua.New(engine.Options{Script: engine.ScriptString(`
+asset.update_time = asset.create_time + 0.000001
+asset.data.update_time = asset.data.create_time
+`)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+	created := time.Date(2022, time.March, 4, 5, 6, 7, 123_456_000, time.UTC)
+	a.CreateTime = timestamppb.New(created)
+
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	if got, want := a.GetUpdateTime().AsTime(), created.Add(time.Microsecond); !got.Equal(want) {
+		t.Errorf("T() update_time = %v, want %v", got, want)
+	}
+
+	var data asset.FeatureTable
+	if err := a.GetData().UnmarshalTo(&data); err != nil {
+		t.Fatalf("UnmarshalTo() error = %v", err)
+	}
+	if got, want := data.GetUpdateTime().AsTime(), data.GetCreateTime().AsTime().Round(time.Microsecond); !got.Equal(want) {
+		t.Errorf("T() data.update_time = %v, want %v", got, want)
+	}
+}
@@ -3,31 +3,45 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/sudo-suhas/xgo/errors"
 	"github.com/sudo-suhas/xgo/httputil"
 	"google.golang.org/protobuf/encoding/protojson"
 
-	"github.com/sudo-suhas/play-script-engine/anko"
-	"github.com/sudo-suhas/play-script-engine/bloblang"
-	"github.com/sudo-suhas/play-script-engine/goja"
-	"github.com/sudo-suhas/play-script-engine/gojq"
-	"github.com/sudo-suhas/play-script-engine/golua"
-	"github.com/sudo-suhas/play-script-engine/gopherlua"
-	"github.com/sudo-suhas/play-script-engine/otto"
+	_ "github.com/sudo-suhas/play-script-engine/anko"
+	"github.com/sudo-suhas/play-script-engine/assetio"
+	"github.com/sudo-suhas/play-script-engine/batch"
+	"github.com/sudo-suhas/play-script-engine/bench"
+	_ "github.com/sudo-suhas/play-script-engine/bloblang"
+	"github.com/sudo-suhas/play-script-engine/conformance"
+	"github.com/sudo-suhas/play-script-engine/engine"
+	_ "github.com/sudo-suhas/play-script-engine/goja"
+	_ "github.com/sudo-suhas/play-script-engine/gojq"
+	_ "github.com/sudo-suhas/play-script-engine/golua"
+	_ "github.com/sudo-suhas/play-script-engine/gopherlua"
+	_ "github.com/sudo-suhas/play-script-engine/otto"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/protoset"
 	"github.com/sudo-suhas/play-script-engine/sample"
-	"github.com/sudo-suhas/play-script-engine/tengo"
+	_ "github.com/sudo-suhas/play-script-engine/tengo"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	lg := log.New()
-	lg.SetOutput(os.Stdout)
+	lg.SetOutput(os.Stderr)
 	lg.SetFormatter(&log.JSONFormatter{
 		DisableHTMLEscape: true,
 		PrettyPrint:       true,
@@ -40,79 +54,324 @@ func main() {
 }
 
 func run(ctx context.Context, args []string, logger log.FieldLogger) error {
-	const op = "run"
-
-	engine := "gojq"
 	if len(args) != 0 {
-		engine = args[0]
+		switch args[0] {
+		case "bench":
+			return runBench(ctx, args[1:])
+
+		case "conformance":
+			return runConformance(ctx, args[1:], logger)
+		}
 	}
 
-	a, err := sample.FeatureTable()
+	return runTransform(ctx, args, logger)
+}
+
+func runTransform(ctx context.Context, args []string, logger log.FieldLogger) error {
+	const op = "runTransform"
+
+	fs := flag.NewFlagSet("play-script-engine", flag.ContinueOnError)
+	name := fs.String("engine", "gojq", "name of the script engine, one of: "+strings.Join(engine.Names(), ", "))
+	scriptPath := fs.String("script", "", "path to the script file, defaults to the script bundled with the engine")
+	inputPath := fs.String("input", "", "path to the input asset, '-' for stdin, defaults to a sample feature table or stdin in batch mode")
+	format := fs.String("format", "", "format of the input asset, one of: json, yaml, binary; inferred from the file extension if unset")
+	batchMode := fs.Bool("batch", false, "transform a stream of newline delimited JSON or length delimited binary assets and write the results to stdout")
+	concurrency := fs.Int("concurrency", 1, "number of assets transformed concurrently in batch mode")
+	timeout := fs.Duration("timeout", 0, "maximum duration of the script run for each asset, no limit if zero")
+	strict := fs.Bool("strict", false, "fail the script on a write to a field which does not exist")
+	fanOut := fs.Bool("fan-out", false, "write every asset produced by a script which produces more than one, such as a jq query with multiple outputs, instead of failing")
+	var descriptorSets []string
+	fs.Func("descriptor-set", "path to a FileDescriptorSet with the types the data of the assets can hold, can be repeated", func(s string) error {
+		descriptorSets = append(descriptorSets, s)
+		return nil
+	})
+	var limits engine.Limits
+	fs.Int64Var(&limits.MaxAllocs, "max-allocs", 0, "maximum number of objects allocated by the script, no limit if zero")
+	fs.Int64Var(&limits.MaxSteps, "max-steps", 0, "maximum number of instructions executed by the script, no limit if zero")
+	fs.IntVar(&limits.MaxCallDepth, "max-call-depth", 0, "maximum depth of the call stack of the script, no limit if zero")
+	fs.IntVar(&limits.MaxOutputSize, "max-output-size", 0, "maximum size in bytes of the transformed asset, no limit if zero")
+	if err := fs.Parse(args); err != nil {
+		return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+
+	if err := protoset.Register(descriptorSets...); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	urler, err := newURLer()
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	ub, err := httputil.NewURLBuilderSource("https://my-dummy-domain.company.com/")
+	opts := engine.Options{URLer: urler, Limits: limits, Strict: *strict, FanOut: *fanOut}
+	if *scriptPath != "" {
+		opts.Script = engine.ScriptFile(*scriptPath)
+	}
+
+	t, err := engine.New(*name, opts)
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
+	t = engine.WithTimeout(t, *timeout)
 
-	urler := func(name string) string { return ub.NewURLBuilder().Path(name).URL().String() }
+	if *batchMode {
+		if *inputPath == "" {
+			*inputPath = "-"
+		}
+		return runBatch(ctx, t, *inputPath, *format, *concurrency, logger)
+	}
 
-	var t transformer
-	switch engine {
-	case "gopherlua":
-		t = &gopherlua.Transformer{URLer: urler}
+	a, err := readAsset(*inputPath, *format)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-	case "otto":
-		t = &otto.Transformer{URLer: urler}
+	res, err := engine.Expand(ctx, t, a)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("transform"), errors.WithErr(err))
+	}
 
-	case "goja":
-		t = &goja.Transformer{URLer: urler}
+	if len(res) == 0 {
+		logger.WithField("urn", a.Urn).Info("Dropped")
+	}
+	for _, a := range res {
+		data, _ := protojson.Marshal(a.Data)
+		logger.WithField("data", json.RawMessage(data)).
+			WithField("asset", a).
+			Info("Transformed")
+	}
 
-	case "bloblang":
-		t = &bloblang.Transformer{URLer: urler}
+	return nil
+}
 
-	case "golua":
-		t = &golua.Transformer{URLer: urler}
+func runBatch(ctx context.Context, t engine.Transformer, path, format string, workers int, logger log.FieldLogger) error {
+	const op = "runBatch"
 
-	case "tengo":
-		t = &tengo.Transformer{URLer: urler}
+	f, err := inputFormat(path, format)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-	case "anko":
-		t = &anko.Transformer{URLer: urler}
+	r := os.Stdin
+	if path != "-" {
+		if r, err = os.Open(path); err != nil {
+			return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+		defer r.Close()
+	}
 
-	case "gojq":
-		t = &gojq.Transformer{URLer: urler}
+	sc, err := assetio.NewScanner(r, f)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-	default:
-		return errors.E(errors.WithOp(op), errors.WithTextf("unknown script engine: %s", engine))
+	enc, err := assetio.NewEncoder(os.Stdout, f)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	if err := t.T(ctx, a); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("transform"), errors.WithErr(err))
+	summary, err := batch.Run(ctx, t, sc, enc, workers)
+	for _, f := range summary.Failures {
+		logger.WithError(f.Err).
+			WithField("index", f.Index).
+			WithField("urn", f.URN).
+			Warn("Record failed")
+	}
+	logger.WithField("total", summary.Total).
+		WithField("succeeded", summary.Succeeded()).
+		WithField("failed", len(summary.Failures)).
+		WithField("dropped", summary.Dropped).
+		WithField("written", summary.Written).
+		Info("Batch completed")
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	data, _ := protojson.Marshal(a.Data)
-	logger.WithField("data", json.RawMessage(data)).
-		WithField("asset", a).
-		Info("Transformed")
+	if len(summary.Failures) != 0 {
+		return errors.E(errors.WithOp(op), errors.WithTextf("%d of %d records failed", len(summary.Failures), summary.Total))
+	}
 
 	return nil
 }
 
-type transformer interface {
-	// T should do the following:
-	// - Add a label to the asset - "script_engine": "<current_script_engine>"
-	// - Add a label to each entity. Ex: "catch_phrase": "..."
-	// - Set an EntityName for each feature based on the following table
-	//   - ongoing_placed_and_waiting_acceptance_orders: customer_orders
-	//   - ongoing_orders: customer_orders
-	//   - merchant_avg_dispatch_arrival_time_10m: merchant_driver
-	//   - ongoing_accepted_orders: merchant_orders
-	// - Set the owner as {Name: Big Mom, Email: big.mom@wholecakeisland.com}
-	// - Set the Url using a function that is passed in
-	// - For each lineage upstream, if the service is Kafka, apply a string
-	//   replace on the URN - {.yonkou.io => }.
-	T(ctx context.Context, a *asset.Asset) error
+func readAsset(path, format string) (*asset.Asset, error) {
+	const op = "readAsset"
+
+	if path == "" {
+		return sample.FeatureTable()
+	}
+
+	f, err := inputFormat(path, format)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	a, err := assetio.ReadFile(path, f)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return a, nil
+}
+
+func inputFormat(path, format string) (assetio.Format, error) {
+	if format == "" {
+		return assetio.FormatFromPath(path), nil
+	}
+	return assetio.ParseFormat(format)
+}
+
+func runBench(ctx context.Context, args []string) error {
+	const op = "runBench"
+
+	fs := flag.NewFlagSet("play-script-engine bench", flag.ContinueOnError)
+	names := fs.String("engines", strings.Join(engine.Names(), ","), "comma separated names of the script engines to benchmark")
+	sizesFlag := fs.String("sizes", joinInts(bench.DefaultSizes), "comma separated numbers of features in the feature tables to benchmark")
+	generated := fs.Int("generated", 100, "number of generated assets to transform in turn")
+	benchtime := fs.Duration("benchtime", time.Second, "time to run each case for")
+	jsonOut := fs.Bool("json", false, "write the results to stdout as JSON")
+	if err := fs.Parse(args); err != nil {
+		return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+
+	sizes, err := splitInts(*sizesFlag)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("sizes"), errors.WithErr(err))
+	}
+
+	urler, err := newURLer()
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	var cases []bench.Case
+	for _, name := range strings.Split(*names, ",") {
+		opts := engine.Options{URLer: urler}
+
+		compile, err := bench.Compile(ctx, name, opts, a)
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		cases = append(cases, compile...)
+
+		gen, err := bench.Generated(ctx, name, opts, *generated)
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		cases = append(cases, gen)
+
+		if len(sizes) == 0 {
+			continue
+		}
+		bySize, err := bench.Sizes(ctx, name, opts, sizes...)
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		cases = append(cases, bySize...)
+	}
+	cases = append(cases, bench.Structmap(a)...)
+
+	results := make([]bench.Result, len(cases))
+	for i, c := range cases {
+		if results[i], err = bench.Measure(c, *benchtime); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "engine\tcase\tfeatures\tn\tns/op\tallocs/op\tB/op\tp50\tp99\t")
+	for _, r := range results {
+		fmt.Fprintf(
+			tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t\n",
+			r.Engine, r.Case, r.Features, r.N, r.NsPerOp, r.AllocsPerOp, r.BytesPerOp,
+			time.Duration(r.P50Ns), time.Duration(r.P99Ns),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+func joinInts(ints []int) string {
+	ss := make([]string, len(ints))
+	for i, n := range ints {
+		ss[i] = strconv.Itoa(n)
+	}
+	return strings.Join(ss, ",")
+}
+
+// splitInts parses a comma separated list of integers. An empty string is
+// an empty list.
+func splitInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ints []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+func runConformance(ctx context.Context, args []string, logger log.FieldLogger) error {
+	const op = "runConformance"
+
+	fs := flag.NewFlagSet("play-script-engine conformance", flag.ContinueOnError)
+	names := fs.String("engines", strings.Join(engine.Names(), ","), "comma separated names of the script engines to check")
+	if err := fs.Parse(args); err != nil {
+		return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+	}
+
+	report, err := conformance.Run(ctx, strings.Split(*names, ",")...)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := report.WriteMatrix(os.Stdout); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	failures := report.Failures()
+	for _, f := range failures {
+		logger.WithError(f.Err).
+			WithField("engine", f.Engine).
+			WithField("case", f.Case).
+			WithField("requirement", f.Requirement).
+			Warn("Requirement not met")
+	}
+	if len(failures) != 0 {
+		return errors.E(errors.WithOp(op), errors.WithTextf("%d of %d checks failed", len(failures), len(report.Results)))
+	}
+
+	return nil
+}
+
+func newURLer() (func(string) string, error) {
+	ub, err := httputil.NewURLBuilderSource("https://my-dummy-domain.company.com/")
+	if err != nil {
+		return nil, err
+	}
+
+	return func(name string) string { return ub.NewURLBuilder().Path(name).URL().String() }, nil
 }
@@ -0,0 +1,28 @@
+asset.labels = _.extend({ script_engine: 'otto' }, asset.labels);
+
+_.each(asset.data.entities, function(e) {
+	e.labels = _.extend({ catch_phrase: 'I\'ll be back' }, e.labels);
+});
+
+_.each(asset.data.features, function(f) {
+	switch (f.name) {
+		case 'ongoing_placed_and_waiting_acceptance_orders':
+		case 'ongoing_orders':
+			f.entity_name = 'customer_orders';
+			break;
+		case 'merchant_avg_dispatch_arrival_time_10m':
+			f.entity_name = 'merchant_driver';
+			break;
+		case 'ongoing_accepted_orders':
+			f.entity_name = 'merchant_orders';
+			break;
+	}
+})
+
+asset.owners = [{ name: 'Big Mom', email: 'big.mom@wholecakeisland.com' }].concat(asset.owners);
+
+asset.url = urler(asset.name);
+
+_.chain(asset.lineage ? asset.lineage.upstreams : [])
+	.filter(function(u) { return u.service === 'kafka'; })
+	.each(function(u) { u.urn = u.urn.replace('.yonkou.io', ''); });
@@ -0,0 +1,210 @@
+package otto
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/robertkrimen/otto"
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+// The messages of repeated and map fields are wrapped by runtime.message
+// as well, in arrays and objects which the script may change in place and
+// which are set back into the fields once the script has run.
+
+// wrapped is a message wrapped by runtime.message.
+type wrapped struct {
+	obj *otto.Object
+	m   protoreflect.Message
+	// dirty is set if the wrapper is in runtime.dirty.
+	dirty bool
+}
+
+// listKey identifies a repeated or map message field of a message.
+type listKey struct {
+	m  protoreflect.Message
+	fd protoreflect.FieldDescriptor
+}
+
+// list returns the repeated message field as an array of the wrapped
+// messages. The array is kept for the run, so that the script sees the
+// changes it makes to it.
+func (r *runtime) list(pm protoreflect.Message, fd protoreflect.FieldDescriptor) (otto.Value, error) {
+	key := listKey{pm, fd}
+	if v, ok := r.lists[key]; ok {
+		return v, nil
+	}
+
+	a, err := r.vm.Object(`[]`)
+	if err != nil {
+		return otto.Value{}, err
+	}
+	l := pm.Get(fd).List()
+	items := make([]interface{}, l.Len())
+	for i := range items {
+		if items[i], err = r.message(l.Get(i).Message().Interface(), nil); err != nil {
+			return otto.Value{}, err
+		}
+	}
+	if len(items) != 0 {
+		if _, err := a.Call("push", items...); err != nil {
+			return otto.Value{}, err
+		}
+	}
+
+	r.lists[key] = a.Value()
+	r.listOrder = append(r.listOrder, key)
+	return a.Value(), nil
+}
+
+// setList sets the repeated message field from the array v. Its elements
+// can be wrapped messages or objects, which are assigned field by field
+// to a new message so that their writes are checked in the same way.
+func (r *runtime) setList(pm protoreflect.Message, fd protoreflect.FieldDescriptor, v otto.Value) error {
+	delete(r.lists, listKey{pm, fd})
+
+	if v.IsUndefined() || v.IsNull() {
+		pm.Clear(fd)
+		return nil
+	}
+	if v.Class() != "Array" {
+		return errors.E(errors.WithTextf("%s must be an array, got %s", fd.Name(), v.Class()))
+	}
+
+	a := v.Object()
+	lv, err := a.Get("length")
+	if err != nil {
+		return err
+	}
+	n, err := lv.ToInteger()
+	if err != nil {
+		return err
+	}
+
+	l := pm.NewField(fd).List()
+	for i := int64(0); i < n; i++ {
+		ev, err := a.Get(strconv.FormatInt(i, 10))
+		if err != nil {
+			return err
+		}
+
+		if w, ok := r.wrappers[ev]; ok {
+			l.Append(protoreflect.ValueOfMessage(w.m))
+			continue
+		}
+		if !ev.IsObject() {
+			return errors.E(errors.WithTextf("%s[%d] must be an object, got %s", fd.Name(), i, ev))
+		}
+
+		e := l.NewElement()
+		if err := r.assign(e.Message(), ev.Object()); err != nil {
+			return err
+		}
+		l.Append(e)
+	}
+	pm.Set(fd, protoreflect.ValueOfList(l))
+
+	return nil
+}
+
+// mapObject returns the map field of messages as an object of the wrapped
+// messages by their keys, which is kept for the run as the arrays of list
+// are.
+func (r *runtime) mapObject(pm protoreflect.Message, fd protoreflect.FieldDescriptor) (otto.Value, error) {
+	key := listKey{pm, fd}
+	if v, ok := r.lists[key]; ok {
+		return v, nil
+	}
+
+	o, err := r.vm.Object(`({})`)
+	if err != nil {
+		return otto.Value{}, err
+	}
+	mp := pm.Get(fd).Map()
+	keys := make([]protoreflect.MapKey, 0, mp.Len())
+	mp.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, k := range keys {
+		v, err := r.message(mp.Get(k).Message().Interface(), nil)
+		if err != nil {
+			return otto.Value{}, err
+		}
+		if err := o.Set(k.String(), v); err != nil {
+			return otto.Value{}, err
+		}
+	}
+
+	r.lists[key] = o.Value()
+	r.listOrder = append(r.listOrder, key)
+	return o.Value(), nil
+}
+
+// setMap sets the map field of messages from the object v, whose values
+// are set as the elements of an array are by setList.
+func (r *runtime) setMap(pm protoreflect.Message, fd protoreflect.FieldDescriptor, v otto.Value) error {
+	delete(r.lists, listKey{pm, fd})
+
+	if v.IsUndefined() || v.IsNull() {
+		pm.Clear(fd)
+		return nil
+	}
+	if v.Class() != "Object" {
+		return errors.E(errors.WithTextf("%s must be an object, got %s", fd.Name(), v.Class()))
+	}
+
+	o := v.Object()
+	mp := pm.NewField(fd).Map()
+	for _, k := range o.Keys() {
+		mk, err := protoutil.MapKey(fd.MapKey(), k)
+		if err != nil {
+			return err
+		}
+		ev, err := o.Get(k)
+		if err != nil {
+			return err
+		}
+
+		if w, ok := r.wrappers[ev]; ok {
+			mp.Set(mk, protoreflect.ValueOfMessage(w.m))
+			continue
+		}
+		if !ev.IsObject() {
+			return errors.E(errors.WithTextf("%s[%q] must be an object, got %s", fd.Name(), k, ev))
+		}
+
+		e := mp.NewValue()
+		if err := r.assign(e.Message(), ev.Object()); err != nil {
+			return err
+		}
+		mp.Set(mk, e)
+	}
+	pm.Set(fd, protoreflect.ValueOfMap(mp))
+
+	return nil
+}
+
+// syncLists sets the arrays and objects of the repeated and map message
+// fields read by the script back into the messages.
+func (r *runtime) syncLists() error {
+	for _, key := range r.listOrder {
+		v, ok := r.lists[key]
+		if !ok {
+			continue
+		}
+		set := r.setList
+		if key.fd.IsMap() {
+			set = r.setMap
+		}
+		if err := set(key.m, key.fd, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
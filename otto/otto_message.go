@@ -0,0 +1,361 @@
+package otto
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/robertkrimen/otto"
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+	"github.com/sudo-suhas/play-script-engine/structmap"
+)
+
+// defineAccessor defines an enumerable property of the object with the
+// getter and setter.
+const defineAccessor = `(function (o, name, get, set) {
+	Object.defineProperty(o, name, {get: get, set: set, enumerable: true});
+})`
+
+// message returns the proto message as seen by the script. otto has no
+// proxies, and looks up the fields of a Go struct by their json tags or Go
+// names, so it is an object with an accessor for each field, named by the
+// proto name, which exposes timestamp fields as Dates and forwards the rest
+// to the Go field named by engine.FieldName, or to the message for the
+// fields of a oneof, which have none. A nested message is wrapped in the
+// same way when it is read. The fields in fixed are read as the given
+// values instead and cannot be assigned, which is how the asset exposes
+// its unpacked data. The messages of repeated and map fields are wrapped
+// too, see runtime.list, and the wrappers are kept for the run so that a
+// message read again is the same object.
+func (r *runtime) message(m proto.Message, fixed map[string]otto.Value) (otto.Value, error) {
+	if w, ok := r.byMessage[m]; ok && fixed == nil {
+		r.touch(w)
+		return w.obj.Value(), nil
+	}
+
+	// Defining the accessors runs script, which must not check the
+	// wrappers being built.
+	r.wrapping++
+	defer func() { r.wrapping-- }()
+
+	pm := m.ProtoReflect()
+
+	tv, err := r.vm.ToValue(m)
+	if err != nil {
+		return otto.Value{}, err
+	}
+	target := tv.Object()
+
+	w, err := r.vm.Object(`({})`)
+	if err != nil {
+		return otto.Value{}, err
+	}
+
+	goNames := goFieldNames(reflect.TypeOf(m))
+	fields := pm.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := string(fd.Name())
+
+		if v, ok := fixed[name]; ok {
+			get := func(call otto.FunctionCall) otto.Value {
+				if w, ok := r.wrappers[v]; ok {
+					r.touch(w)
+				}
+				return v
+			}
+			set := func(call otto.FunctionCall) otto.Value {
+				panic(r.vm.MakeTypeError(name + " cannot be replaced, modify its fields instead"))
+			}
+			if _, err := r.defineAccessor.Call(otto.NullValue(), w, name, get, set); err != nil {
+				return otto.Value{}, err
+			}
+			continue
+		}
+
+		goName, ok := goNames[name]
+		get := func(call otto.FunctionCall) otto.Value {
+			if fd.IsList() && fd.Message() != nil {
+				return r.must(r.list(pm, fd))
+			}
+			if fd.IsMap() && fd.MapValue().Message() != nil {
+				return r.must(r.mapObject(pm, fd))
+			}
+			if fd.IsList() || fd.IsMap() || fd.Message() == nil {
+				if !ok {
+					return r.must(r.scalar(pm, fd))
+				}
+				return r.must(target.Get(goName))
+			}
+			if !pm.Has(fd) {
+				return otto.NullValue()
+			}
+
+			v := pm.Get(fd).Message().Interface()
+			if ts, ok := v.(*timestamppb.Timestamp); ok {
+				return r.must(r.vm.Call("new Date", nil, ts.AsTime().UnixMilli()))
+			}
+			return r.must(r.message(v, nil))
+		}
+
+		set := func(call otto.FunctionCall) otto.Value {
+			value := call.Argument(0)
+			if fd.IsList() && fd.Message() != nil {
+				if err := r.setList(pm, fd, value); err != nil {
+					panic(r.vm.MakeTypeError(err.Error()))
+				}
+				return otto.UndefinedValue()
+			}
+			if fd.IsMap() && fd.MapValue().Message() != nil {
+				if err := r.setMap(pm, fd, value); err != nil {
+					panic(r.vm.MakeTypeError(err.Error()))
+				}
+				return otto.UndefinedValue()
+			}
+			if fd.Message() != nil && !fd.IsMap() && !protoutil.IsTimestamp(fd) {
+				if err := r.setMessage(pm, fd, value); err != nil {
+					panic(r.vm.MakeTypeError(err.Error()))
+				}
+				return otto.UndefinedValue()
+			}
+			if !protoutil.IsTimestamp(fd) {
+				set := func() error { return target.Set(goName, value) }
+				if !ok {
+					set = func() error { return setScalar(pm, fd, value) }
+				}
+				if err := set(); err != nil {
+					panic(r.vm.MakeTypeError(err.Error()))
+				}
+				return otto.UndefinedValue()
+			}
+
+			if value.IsUndefined() || value.IsNull() {
+				pm.Clear(fd)
+				return otto.UndefinedValue()
+			}
+
+			if value.Class() != "Date" {
+				panic(r.vm.MakeTypeError(name + " must be a Date, got " + value.String()))
+			}
+			ms, err := r.must(value.Object().Call("getTime")).ToInteger()
+			if err != nil {
+				panic(r.vm.MakeTypeError(err.Error()))
+			}
+			pm.Set(fd, protoreflect.ValueOfMessage(timestamppb.New(time.UnixMilli(ms)).ProtoReflect()))
+			return otto.UndefinedValue()
+		}
+
+		if _, err := r.defineAccessor.Call(otto.NullValue(), w, name, get, set); err != nil {
+			return otto.Value{}, err
+		}
+	}
+
+	wr := &wrapped{obj: w, m: pm}
+	r.wrappers[w.Value()] = wr
+	r.wrapperOrder = append(r.wrapperOrder, wr)
+	if fixed == nil {
+		r.byMessage[m] = wr
+	} else {
+		// The asset, which the script can write to at any step.
+		r.root = wr
+	}
+	r.touch(wr)
+	return w.Value(), nil
+}
+
+// goNames holds the result of goFieldNames by the type of the message.
+var goNames sync.Map
+
+// goFieldNames returns the names of the fields of the Go struct of the
+// message by the names given to them by engine.FieldName, under which the
+// accessors of message look them up in otto.
+func goFieldNames(t reflect.Type) map[string]string {
+	if names, ok := goNames.Load(t); ok {
+		return names.(map[string]string)
+	}
+
+	st := t.Elem()
+	names := make(map[string]string, st.NumField())
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if name := engine.FieldName(f); name != "" {
+			names[name] = f.Name
+		}
+	}
+	goNames.Store(t, names)
+	return names
+}
+
+// scalar returns the scalar field of a oneof, an enum as its number.
+func (r *runtime) scalar(pm protoreflect.Message, fd protoreflect.FieldDescriptor) (otto.Value, error) {
+	if !pm.Has(fd) {
+		return otto.NullValue(), nil
+	}
+
+	v := pm.Get(fd).Interface()
+	if e, ok := v.(protoreflect.EnumNumber); ok {
+		v = int32(e)
+	}
+	return r.vm.ToValue(v)
+}
+
+// setScalar sets the scalar field of a oneof to the value, which is
+// converted as structmap.Decode converts the value of a field.
+func setScalar(pm protoreflect.Message, fd protoreflect.FieldDescriptor, v otto.Value) error {
+	if v.IsUndefined() || v.IsNull() {
+		pm.Clear(fd)
+		return nil
+	}
+
+	ev, err := v.Export()
+	if err != nil {
+		return err
+	}
+	m := pm.New()
+	if err := structmap.Decode(map[string]interface{}{string(fd.Name()): ev}, m.Interface()); err != nil {
+		return err
+	}
+	pm.Set(fd, m.Get(fd))
+	return nil
+}
+
+// setMessage sets the message field to a copy of the object, which is
+// assigned to a wrapper of the new message field by field so that nested
+// timestamps, and repeated messages, are converted as they are when
+// assigned one by one. A wrapper of a message is set as is.
+func (r *runtime) setMessage(pm protoreflect.Message, fd protoreflect.FieldDescriptor, v otto.Value) error {
+	if v.IsUndefined() || v.IsNull() {
+		pm.Clear(fd)
+		return nil
+	}
+	if w, ok := r.wrappers[v]; ok {
+		pm.Set(fd, protoreflect.ValueOfMessage(w.m))
+		return nil
+	}
+	if !v.IsObject() {
+		return errors.E(errors.WithTextf("%s must be an object, got %s", fd.Name(), v))
+	}
+
+	m := pm.NewField(fd)
+	if err := r.assign(m.Message(), v.Object()); err != nil {
+		return err
+	}
+	pm.Set(fd, m)
+	return nil
+}
+
+// assign sets the properties of the object src on a wrapper of the message.
+func (r *runtime) assign(m protoreflect.Message, src *otto.Object) error {
+	w, err := r.message(m.Interface(), nil)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range src.Keys() {
+		v, err := src.Get(k)
+		if err != nil {
+			return err
+		}
+		if err := w.Object().Set(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// data returns the data of the asset as seen by the script and a function
+// which must be called once the script has run, before the runtime is
+// reset. A dynamic message, of a type registered by protoset, has no Go
+// struct to reflect on. It is a plain object in the form returned by
+// structmap.Encode instead, with timestamps as Dates, which decode sets
+// back into the message. otto exports arrays as slices of the type common
+// to their elements, so the object is read back through JSON.
+func (r *runtime) data(m proto.Message) (v otto.Value, decode func() error, err error) {
+	if _, ok := m.(*dynamicpb.Message); !ok {
+		v, err := r.message(m, nil)
+		return v, func() error { return nil }, err
+	}
+
+	enc, err := structmap.Encode(m, structmap.Format{Time: structmap.TimeGo})
+	if err != nil {
+		return otto.Value{}, nil, err
+	}
+
+	if v, err = r.value(enc); err != nil {
+		return otto.Value{}, nil, err
+	}
+
+	return v, func() error {
+		s, err := r.stringify.Call(otto.NullValue(), v)
+		if err != nil {
+			return err
+		}
+
+		var dv interface{}
+		if err := json.Unmarshal([]byte(s.String()), &dv); err != nil {
+			return err
+		}
+
+		return structmap.Decode(dv, m)
+	}, nil
+}
+
+// value converts the value in the form returned by structmap.Encode into
+// plain objects and arrays.
+func (r *runtime) value(v interface{}) (otto.Value, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		o, err := r.vm.Object(`({})`)
+		if err != nil {
+			return otto.Value{}, err
+		}
+		for k, e := range v {
+			ev, err := r.value(e)
+			if err != nil {
+				return otto.Value{}, err
+			}
+			if err := o.Set(k, ev); err != nil {
+				return otto.Value{}, err
+			}
+		}
+		return o.Value(), nil
+
+	case []interface{}:
+		a, err := r.vm.Object(`[]`)
+		if err != nil {
+			return otto.Value{}, err
+		}
+		items := make([]interface{}, len(v))
+		for i, e := range v {
+			if items[i], err = r.value(e); err != nil {
+				return otto.Value{}, err
+			}
+		}
+		if _, err := a.Call("push", items...); err != nil {
+			return otto.Value{}, err
+		}
+		return a.Value(), nil
+
+	case time.Time:
+		return r.vm.Call("new Date", nil, v.UnixMilli())
+
+	default:
+		return r.vm.ToValue(v)
+	}
+}
+
+// must returns the value or throws the error in the script.
+func (r *runtime) must(v otto.Value, err error) otto.Value {
+	if err != nil {
+		panic(r.vm.MakeCustomError("Error", err.Error()))
+	}
+	return v
+}
@@ -0,0 +1,126 @@
+package otto
+
+import (
+	"github.com/robertkrimen/otto/ast"
+	"github.com/robertkrimen/otto/file"
+	"github.com/robertkrimen/otto/parser"
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// otto has no proxies, nor does it enforce strict mode, so a write to a
+// property which is not a field cannot be trapped. In strict mode, the
+// property is left on the object wrapping the message and the interrupt
+// queued by watch, which runs before each statement and expression, finds
+// it with checkWritten and throws a TypeError. The interrupt does not know
+// the statement which set the property, so the error is located at the
+// first assignment to a property of that name in the script, see sites.
+
+// touch marks the wrapper as handed to the script, which can write to it
+// from then on.
+func (r *runtime) touch(w *wrapped) {
+	if r.strict && !w.dirty {
+		w.dirty = true
+		r.dirty = append(r.dirty, w)
+	}
+}
+
+// checkWritten checks the wrappers the script may have just written to:
+// the asset, the wrappers handed to the script since the last check and,
+// in turn, one of the others. Checking every wrapper at each step is too
+// slow for an asset with thousands of messages, while a write through a
+// wrapper kept by the script is still found within as many steps as there
+// are wrappers, and by the check once the script has run at the latest.
+func (r *runtime) checkWritten() error {
+	if r.root != nil {
+		if err := r.checkFields([]*wrapped{r.root}); err != nil {
+			return err
+		}
+	}
+	if err := r.checkFields(r.dirty); err != nil {
+		return err
+	}
+	for _, w := range r.dirty {
+		w.dirty = false
+	}
+	r.dirty = r.dirty[:0]
+
+	if len(r.wrapperOrder) == 0 {
+		return nil
+	}
+	r.sweep %= len(r.wrapperOrder)
+	w := r.wrapperOrder[r.sweep]
+	r.sweep++
+	return r.checkFields([]*wrapped{w})
+}
+
+// checkFields reports the first property set on the wrapped messages which
+// is not a field, in order.
+func (r *runtime) checkFields(ws []*wrapped) error {
+	for _, w := range ws {
+		fields := w.m.Descriptor().Fields()
+		for _, k := range w.obj.Keys() {
+			if fields.ByName(protoreflect.Name(k)) != nil {
+				continue
+			}
+			if pos, ok := r.sites[k]; ok {
+				return errors.E(errors.WithTextf("unknown field %s of %s at %s", k, w.m.Descriptor().FullName(), pos))
+			}
+			return errors.E(errors.WithTextf("unknown field %s of %s", k, w.m.Descriptor().FullName()))
+		}
+	}
+
+	return nil
+}
+
+// sites returns the position of the first assignment to a property in the
+// source, such as asset.lables = {} or asset["lables"] = {}, by the name
+// of the property.
+func sites(filename, src string) (map[string]*file.Position, error) {
+	fset := &file.FileSet{}
+	program, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// FileSet.Position subtracts the base of the file twice, so the
+	// position is taken from the file.
+	v := siteVisitor{file: program.File, sites: make(map[string]*file.Position)}
+	ast.Walk(&v, program)
+	return v.sites, nil
+}
+
+type siteVisitor struct {
+	file  *file.File
+	sites map[string]*file.Position
+}
+
+func (v *siteVisitor) Enter(n ast.Node) ast.Visitor {
+	assign, ok := n.(*ast.AssignExpression)
+	if !ok {
+		return v
+	}
+
+	var (
+		name string
+		idx  file.Idx
+	)
+	switch left := assign.Left.(type) {
+	case *ast.DotExpression:
+		name, idx = left.Identifier.Name, left.Identifier.Idx
+	case *ast.BracketExpression:
+		lit, ok := left.Member.(*ast.StringLiteral)
+		if !ok {
+			return v
+		}
+		name, idx = lit.Value, lit.Idx
+	default:
+		return v
+	}
+	if _, ok := v.sites[name]; !ok {
+		v.sites[name] = v.file.Position(idx)
+	}
+	return v
+}
+
+func (v *siteVisitor) Exit(ast.Node) {}
@@ -2,90 +2,448 @@ package otto
 
 import (
 	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
 
 	"github.com/robertkrimen/otto"
+	"github.com/robertkrimen/otto/file"
 	_ "github.com/robertkrimen/otto/underscore" // add _ helpers to JS env
 	"github.com/sudo-suhas/xgo/errors"
-	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
 )
 
-var script = `
-asset.labels = _.extend({ script_engine: 'otto' }, asset.labels);
+//go:embed default.js
+var defaultScript string
 
-_.each(data.entities, function(e) {
-	e.labels = _.extend({ catch_phrase: 'I\'ll be back' }, e.labels);
-});
+func init() {
+	engine.Register("otto", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
+}
+
+// Transformer is safe for concurrent use. Each call to T runs the script
+// in a runtime taken from a pool. The script is run inside a function so
+// that its declarations do not outlive the run and the global object is
+// reset before the runtime is returned to the pool. The built-ins, such as
+// Object.prototype, are frozen.
+type Transformer struct {
+	// template is the runtime with the frozen built-ins which is copied
+	// for each runtime of the pool, as freezing them is slow.
+	template *otto.Otto
+	script   *otto.Script
+	limits   engine.Limits
+	strict   bool
+	// sites holds the positions of the assignments to properties in the
+	// script, by the name of the property, in strict mode.
+	sites map[string]*file.Position
+	pool  sync.Pool
+}
+
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "otto.New"
 
-_.each(data.features, function(f) {
-	switch (f.name) {
-		case 'ongoing_placed_and_waiting_acceptance_orders':
-		case 'ongoing_orders':
-			f.EntityName = 'customer_orders';
-			break;
-		case 'merchant_avg_dispatch_arrival_time_10m':
-			f.EntityName = 'merchant_driver';
-			break;
-		case 'ongoing_accepted_orders':
-			f.EntityName = 'merchant_orders';
-			break;
+	if err := opts.Limits.Supported("otto", engine.LimitMaxSteps, engine.LimitMaxCallDepth); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
-})
 
-asset.owners = [{ name: 'Big Mom', email: 'big.mom@wholecakeisland.com' }].concat(asset.owners);
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-asset.url = urler(asset.name);
+	template, err := newTemplate(opts)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-_.chain(asset.lineage.upstreams)
-	.filter(function(u) { return u.service === 'kafka'; })
-	.each(function(u) { u.urn = u.urn.replace('.yonkou.io', ''); });
-`
+	src := "(function () {" + script + "\n})();"
+	compiled, err := template.Compile("script.js", src)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("compile js script"), errors.WithErr(err))
+	}
 
-type Transformer struct {
-	URLer func(string) string
+	t := Transformer{template: template, script: compiled, limits: opts.Limits, strict: opts.Strict}
+	if opts.Strict {
+		if t.sites, err = sites("script.js", src); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("parse js script"), errors.WithErr(err))
+		}
+	}
+	rt, err := t.newRuntime()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	t.pool.Put(rt)
+
+	return &t, nil
 }
 
-func (t *Transformer) T(_ context.Context, a *asset.Asset) (err error) {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "otto.Transform"
 
+	urn := a.GetUrn()
 	defer func() {
 		if r := recover(); r != nil {
-			if e, ok := r.(error); ok {
-				err = errors.E(errors.WithOp(op), errors.WithText("panic recovered"), errors.WithErr(e))
-				return
+			switch r {
+			case errHalt:
+				err = errors.E(errors.WithOp(op), errors.WithErr(engine.ContextError(ctx)))
+
+			case errStepLimit:
+				err = errors.E(errors.WithOp(op), errors.WithErr(
+					engine.LimitExceeded(engine.LimitMaxSteps, t.limits.MaxSteps, nil),
+				))
+
+			default:
+				err = engine.Recovered(op, "otto", urn, r)
 			}
-			err = errors.E(errors.WithOp(op), errors.WithTextf("%v", r), errors.WithData(r))
 		}
 	}()
 
+	a, commit := engine.Stage(a)
+
+	if err := engine.ContextError(ctx); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
 	data, err := a.Data.UnmarshalNew()
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	rt, err := t.runtime()
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	dv, decodeData, err := rt.data(data)
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	av, err := rt.message(a, map[string]otto.Value{"data": dv})
+	if err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	if err := rt.vm.Set("asset", av); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	// The runtime is not returned to the pool if the script is halted as
+	// the panic unwinds the stack before reset.
+	if t.limits.MaxSteps != 0 || t.strict {
+		rt.watch(ctx, t.limits.MaxSteps)
+	}
+	stop := engine.Interrupt(ctx, func() {
+		// If the buffer is full, it holds the interrupt queued by watch
+		// which halts the script itself once the context is done.
+		select {
+		case rt.vm.Interrupt <- func() { panic(errHalt) }:
+		default:
+		}
+	})
+	// The script panics out of Run when it is halted or exceeds
+	// MaxSteps, which skips the call to stop below.
+	defer stop()
+	_, runErr := rt.vm.Run(t.script)
+	stop()
+	// Drop the interrupt queued by watch or the one sent after the script
+	// had finished.
+	select {
+	case <-rt.vm.Interrupt:
+	default:
+	}
+	// The error of a write to an unknown field is reported even if the
+	// script caught the TypeError thrown for it.
+	strictErr := rt.strictErr
+	var listErr, decodeErr error
+	if runErr == nil {
+		listErr = rt.syncLists()
+	}
+	// The interrupt runs before each statement, so the fields are checked
+	// once more for a write by the last one.
+	if strictErr == nil && runErr == nil && listErr == nil && t.strict {
+		strictErr = rt.checkFields(rt.wrapperOrder)
+	}
+	if runErr == nil && listErr == nil && strictErr == nil {
+		decodeErr = decodeData()
+	}
+
+	if err := rt.reset(); err == nil {
+		t.pool.Put(rt)
+	}
+	if strictErr != nil {
+		return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(strictErr))
+	}
+	if t.limits.MaxCallDepth != 0 && runErr != nil && strings.Contains(runErr.Error(), "Maximum call stack size exceeded") {
+		return errors.E(errors.WithOp(op), errors.WithErr(
+			engine.LimitExceeded(engine.LimitMaxCallDepth, int64(t.limits.MaxCallDepth), runErr),
+		))
+	}
+	if runErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("execute js script"), errors.WithErr(runErr))
+	}
+	if listErr != nil {
+		return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(listErr))
+	}
+	if decodeErr != nil {
+		return errors.E(errors.WithOp(op), errors.WithText("decode data"), errors.WithErr(decodeErr))
+	}
+
+	if err := engine.PackData(a, data); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := t.limits.CheckOutput(a); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	commit()
+
+	return nil
+}
+
+// Values the script panics with when it is interrupted because the
+// context is done or it has run too many steps.
+var (
+	errHalt      = errors.E(errors.WithText("halt script"))
+	errStepLimit = errors.E(errors.WithText("step limit exceeded"))
+)
+
+func (t *Transformer) runtime() (*runtime, error) {
+	if rt, ok := t.pool.Get().(*runtime); ok {
+		return rt, nil
+	}
+
+	return t.newRuntime()
+}
+
+// newTemplate returns the runtime with the globals required by the script
+// and the built-ins frozen.
+func newTemplate(opts engine.Options) (*otto.Otto, error) {
+	const op = "otto.newTemplate"
+
 	vm := otto.New()
-	for name, v := range map[string]interface{}{
-		"asset": a,
-		"data":  data,
-		"urler": func(call otto.FunctionCall) otto.Value {
-			v, _ := vm.ToValue(t.URLer(call.Argument(0).String()))
-			return v
-		},
-	} {
-		if err := vm.Set(name, v); err != nil {
-			return errors.E(errors.WithOp(op), errors.WithErr(err))
+	if opts.Limits.MaxCallDepth != 0 {
+		vm.SetStackDepthLimit(opts.Limits.MaxCallDepth)
+	}
+	if err := vm.Set("urler", func(call otto.FunctionCall) otto.Value {
+		v, _ := call.Otto.ToValue(opts.URLer(call.Argument(0).String()))
+		return v
+	}); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	console, err := vm.Object("console")
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	for _, name := range []string{"log", "debug", "info", "error", "warn"} {
+		if err := console.Set(name, consoleLog(opts.Printer())); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 		}
 	}
 
-	if _, err := vm.Run(script); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("execute js script"), errors.WithErr(err))
+	if _, err := vm.Run(freezeBuiltins); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("freeze built-ins"), errors.WithErr(err))
+	}
+
+	return vm, nil
+}
+
+func (t *Transformer) newRuntime() (*runtime, error) {
+	const op = "otto.newRuntime"
+
+	vm := t.template.Copy()
+	// otto yields before each statement once there is an interrupt
+	// channel, so the template has none.
+	vm.Interrupt = make(chan func(), 1)
+
+	define, err := vm.Eval(defineAccessor)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+	stringify, err := vm.Eval("JSON.stringify")
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	a.Data, err = anypb.New(data)
+	global, err := vm.Object("this")
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	globals := make(map[string]otto.Value)
+	for _, k := range global.Keys() {
+		if globals[k], err = global.Get(k); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
+
+	return &runtime{
+		vm:             vm,
+		defineAccessor: define,
+		stringify:      stringify,
+		global:         global,
+		globals:        globals,
+		strict:         t.strict,
+		sites:          t.sites,
+		wrappers:       make(map[otto.Value]*wrapped),
+		byMessage:      make(map[proto.Message]*wrapped),
+		lists:          make(map[listKey]otto.Value),
+	}, nil
+}
+
+// freezeBuiltins freezes the values of the properties of the global object
+// and everything reachable from them, including prototypes and the _ of
+// underscore, but not the global object itself, which is reset instead.
+// otto does not enforce strict mode, so writes to them are ignored.
+const freezeBuiltins = `(function (global) {
+	// An object which is frozen has been seen.
+	function freeze(o) {
+		if (o === null || (typeof o !== "object" && typeof o !== "function") || o === global || Object.isFrozen(o)) {
+			return;
+		}
+		Object.freeze(o);
+		// otto panics on the descriptors of some of its internal
+		// accessors, so the properties are read instead.
+		Object.getOwnPropertyNames(o).forEach(function (k) {
+			var v;
+			try {
+				v = o[k];
+			} catch (e) {
+				return;
+			}
+			freeze(v);
+		});
+		freeze(Object.getPrototypeOf(o));
+	}
+	Object.getOwnPropertyNames(global).forEach(function (k) {
+		freeze(global[k]);
+	});
+})(this);`
+
+// consoleLog returns a console function which writes its arguments to w as
+// the console of otto writes them to stdout.
+func consoleLog(w io.Writer) func(call otto.FunctionCall) otto.Value {
+	return func(call otto.FunctionCall) otto.Value {
+		args := make([]string, len(call.ArgumentList))
+		for i, v := range call.ArgumentList {
+			args[i] = fmt.Sprintf("%v", v)
+		}
+		engine.Println(w, " ", args)
+		return otto.UndefinedValue()
+	}
+}
+
+// runtime is an otto runtime with the globals required by the script.
+type runtime struct {
+	vm *otto.Otto
+	// defineAccessor is the function of the same name.
+	defineAccessor otto.Value
+	// stringify is JSON.stringify as it was before the script ran.
+	stringify otto.Value
+	global    *otto.Object
+	// globals holds the properties of the global object after the runtime
+	// was initialised.
+	globals map[string]otto.Value
+
+	// strict is set if writes to unknown fields are checked, see
+	// engine.Options.Strict.
+	strict bool
+	sites  map[string]*file.Position
+	// strictErr is set by the interrupt queued by watch once it finds a
+	// write to an unknown field.
+	strictErr error
+	// wrappers holds the messages wrapped for the script, by the wrapping
+	// object and by the message, and wrapperOrder in the order they were
+	// wrapped. root is the wrapper of the asset.
+	wrappers     map[otto.Value]*wrapped
+	byMessage    map[proto.Message]*wrapped
+	wrapperOrder []*wrapped
+	root         *wrapped
+	// dirty holds the wrappers handed to the script since the fields were
+	// last checked and sweep is the index of the next wrapper to check in
+	// turn, see checkWritten.
+	dirty []*wrapped
+	sweep int
+	// wrapping is set while messages are being wrapped.
+	wrapping int
+	// lists holds the arrays of the repeated message fields read by the
+	// script, in the order they were read.
+	lists     map[listKey]otto.Value
+	listOrder []listKey
+}
+
+// reset restores the global object to its state after initialisation.
+func (r *runtime) reset() error {
+	for k := range r.wrappers {
+		delete(r.wrappers, k)
+	}
+	for k := range r.byMessage {
+		delete(r.byMessage, k)
+	}
+	r.wrapperOrder = r.wrapperOrder[:0]
+	r.dirty = r.dirty[:0]
+	r.root, r.sweep, r.strictErr = nil, 0, nil
+	for k := range r.lists {
+		delete(r.lists, k)
+	}
+	r.listOrder = r.listOrder[:0]
+
+	for _, k := range r.global.Keys() {
+		if _, ok := r.globals[k]; ok {
+			continue
+		}
+		if _, err := r.vm.Call("(function (k) { delete this[k]; })", nil, k); err != nil {
+			return err
+		}
+	}
+
+	for k, v := range r.globals {
+		if err := r.global.Set(k, v); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// watch queues an interrupt which otto runs before each statement and
+// expression. The interrupt queues itself again and halts the script once
+// the context is done or the count of steps exceeds maxSteps, if not 0. In
+// strict mode, it throws a TypeError once a property which is not a field
+// is set on a wrapped message, see checkFields.
+func (r *runtime) watch(ctx context.Context, maxSteps int64) {
+	var (
+		n    int64
+		step func()
+	)
+	step = func() {
+		// The interrupt is queued first so that it keeps running after a
+		// TypeError caught by the script.
+		select {
+		case r.vm.Interrupt <- step:
+		default:
+		}
+		if ctx.Err() != nil {
+			panic(errHalt)
+		}
+		if n++; maxSteps != 0 && n > maxSteps {
+			panic(errStepLimit)
+		}
+		if r.strict && r.strictErr == nil && r.wrapping == 0 {
+			if r.strictErr = r.checkWritten(); r.strictErr != nil {
+				panic(r.vm.MakeTypeError(r.strictErr.Error()))
+			}
+		}
+	}
+	r.vm.Interrupt <- step
+}
@@ -0,0 +1,282 @@
+package otto_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/otto"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+// leakScript reports what it sees of the changes it makes to the global
+// object and the built-ins, which must not be seen by the next run.
+const leakScript = `
+asset.description = [typeof leak, typeof Object.prototype.leak, typeof Math.leak, typeof [].leak, typeof _.leak].join(",");
+leak = "leaked";
+Object.prototype.leak = "leaked";
+Math.leak = "leaked";
+Array.prototype.leak = "leaked";
+_.leak = "leaked";
+`
+
+func TestTransformerReset(t *testing.T) {
+	tr, err := otto.New(engine.Options{Script: engine.ScriptString(leakScript)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		a, err := sample.FeatureTable()
+		if err != nil {
+			t.Fatalf("sample.FeatureTable() error = %v", err)
+		}
+
+		if err := tr.T(context.Background(), a); err != nil {
+			t.Fatalf("run %d: T() error = %v", i, err)
+		}
+
+		if want := "undefined,undefined,undefined,undefined,undefined"; a.Description != want {
+			t.Errorf("run %d: description = %q, want %q", i, a.Description, want)
+		}
+	}
+}
+
+func TestTransformerStepLimit(t *testing.T) {
+	tr, err := otto.New(engine.Options{
+		Script: engine.ScriptString(`for (;;) {}`),
+		Limits: engine.Limits{MaxSteps: 1000},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 50; i++ {
+		err := tr.T(ctx, a)
+		if errors.WhatKind(err) != errors.ResourceExhausted {
+			t.Fatalf("T() error = %v, want kind %v", err, errors.ResourceExhausted)
+		}
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("T() leaked %d goroutines", after-before)
+	}
+}
+
+// TestTransformerStrict checks that a write to an unknown field stops the
+// script when it is made, even if the script catches the error, and that
+// the first of several is reported.
+func TestTransformerStrict(t *testing.T) {
+	tr, err := otto.New(engine.Options{
+		Script: engine.ScriptString(`
+asset.owners = [{urn: "u"}];
+asset.owners[0].emial = "a";
+try {
+	asset.lables = {};
+} catch (e) {}
+for (;;) {}
+`),
+		Strict: true,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for i := 0; i < 10; i++ {
+		err := tr.T(ctx, a)
+		if errors.WhatKind(err) != errors.InvalidInput {
+			t.Fatalf("run %d: T() error = %v, want kind %v", i, err, errors.InvalidInput)
+		}
+		if want := "unknown field emial of odpf.assets.v1beta2.Owner at script.js:3:17"; !strings.Contains(err.Error(), want) {
+			t.Errorf("run %d: T() error = %v, want %q", i, err, want)
+		}
+	}
+}
+
+// TestTransformerStrictLarge checks that strict mode stays fast on a
+// feature table with many features, as the messages are checked only once
+// the script can have written to them.
+func TestTransformerStrictLarge(t *testing.T) {
+	const features = 3000
+
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+	var ft asset.FeatureTable
+	if err := a.Data.UnmarshalTo(&ft); err != nil {
+		t.Fatalf("UnmarshalTo() error = %v", err)
+	}
+	for i := len(ft.Features); i < features; i++ {
+		ft.Features = append(ft.Features, &asset.Feature{Name: fmt.Sprintf("feature_%d", i), DataType: "INT64"})
+	}
+	if a.Data, err = anypb.New(&ft); err != nil {
+		t.Fatalf("anypb.New() error = %v", err)
+	}
+
+	cases := []struct {
+		name string
+		// script is the default script if empty.
+		script string
+		err    string
+	}{
+		{name: "default"},
+		{
+			name:   "typo",
+			script: `asset.data.features.forEach(function (f, i) { if (i === asset.data.features.length - 1) { f.entity_nam = "x"; } });`,
+			err:    "unknown field entity_nam of odpf.assets.v1beta2.Feature at script.js:1:",
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			opts := engine.Options{URLer: func(s string) string { return s }, Strict: true}
+			if c.script != "" {
+				opts.Script = engine.ScriptString(c.script)
+			}
+			tr, err := otto.New(opts)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			// A run takes well under a second, it took minutes when each
+			// step checked every message.
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+
+			err = tr.T(ctx, proto.Clone(a).(*asset.Asset))
+			if c.err == "" && err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+			if c.err != "" && (errors.WhatKind(err) != errors.InvalidInput || !strings.Contains(err.Error(), c.err)) {
+				t.Fatalf("T() error = %v, want %q", err, c.err)
+			}
+		})
+	}
+}
+
+func TestTransformerRepeatedTime(t *testing.T) {
+	tr, err := otto.New(engine.Options{Script: engine.ScriptString(`
+var blob = asset.data.blobs[0];
+if (!(blob.create_time instanceof Date)) {
+	throw new TypeError("create_time is not a Date");
+}
+blob.update_time = new Date(blob.create_time.getTime() + 60 * 60 * 1000);
+`)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.Bucket()
+	if err != nil {
+		t.Fatalf("sample.Bucket() error = %v", err)
+	}
+
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	var data asset.Bucket
+	if err := a.GetData().UnmarshalTo(&data); err != nil {
+		t.Fatalf("UnmarshalTo() error = %v", err)
+	}
+	blob := data.GetBlobs()[0]
+	want := blob.GetCreateTime().AsTime().Add(time.Hour)
+	if got := blob.GetUpdateTime().AsTime(); !got.Equal(want) {
+		t.Errorf("T() update_time = %v, want %v", got, want)
+	}
+}
+
+func TestTransformerNestedTime(t *testing.T) {
+	tr, err := otto.New(engine.Options{Script: engine.ScriptString(`
+asset.event = {action: "x", timestamp: new Date(1600000000123)};
+asset.data.blobs = [{name: "b", create_time: new Date(1600000000123)}];
+`)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.Bucket()
+	if err != nil {
+		t.Fatalf("sample.Bucket() error = %v", err)
+	}
+
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	want := time.UnixMilli(1600000000123)
+	if a.GetEvent().GetAction() != "x" || !a.GetEvent().GetTimestamp().AsTime().Equal(want) {
+		t.Errorf("T() event = %v, want the action x at %v", a.GetEvent(), want)
+	}
+
+	var data asset.Bucket
+	if err := a.GetData().UnmarshalTo(&data); err != nil {
+		t.Fatalf("UnmarshalTo() error = %v", err)
+	}
+	if blobs := data.GetBlobs(); len(blobs) != 1 || !blobs[0].GetCreateTime().AsTime().Equal(want) {
+		t.Errorf("T() blobs = %v, want one created at %v", blobs, want)
+	}
+}
+
+// TestTransformerStruct checks that the values of a google.protobuf.Struct,
+// a map of messages with a oneof, are read and written by their proto
+// names, which hides the Go names of the fields and of the oneof.
+func TestTransformerStruct(t *testing.T) {
+	tr, err := otto.New(engine.Options{Script: engine.ScriptString(`
+var fields = asset.data.attributes.fields;
+if (fields.image.Kind !== undefined || fields.image.kind !== undefined) {
+	throw new TypeError("the oneof is exposed");
+}
+fields.image.string_value = fields.image.string_value + ":latest";
+fields.replicas = {number_value: fields.replicas.number_value + 1};
+delete fields.endpoints;
+`)})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a, err := sample.Application()
+	if err != nil {
+		t.Fatalf("sample.Application() error = %v", err)
+	}
+
+	if err := tr.T(context.Background(), a); err != nil {
+		t.Fatalf("T() error = %v", err)
+	}
+
+	var data asset.Application
+	if err := a.GetData().UnmarshalTo(&data); err != nil {
+		t.Fatalf("UnmarshalTo() error = %v", err)
+	}
+	got := data.GetAttributes().AsMap()
+	want := map[string]interface{}{"image": "gcr.io/gofood/merchant-eta-service:latest", "replicas": float64(5)}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("T() attributes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+// Package protoset registers the types of FileDescriptorSets loaded at
+// runtime, so that assets whose data holds a message which is not compiled
+// into the binary can be read and transformed.
+package protoset
+
+import (
+	"os"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Register reads the FileDescriptorSets in the files at the given paths and
+// registers them with RegisterSet. A set is written by
+// `buf build -o set.binpb` or
+// `protoc --include_imports --descriptor_set_out=set.binpb`.
+func Register(paths ...string) error {
+	const op = "protoset.Register"
+
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+
+		var set descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(b, &set); err != nil {
+			return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithTextf("unmarshal %s", path), errors.WithErr(err))
+		}
+
+		if err := RegisterSet(&set); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithTextf("register %s", path), errors.WithErr(err))
+		}
+	}
+
+	return nil
+}
+
+// RegisterSet registers the files of the set in protoregistry.GlobalFiles
+// and their messages and enums in protoregistry.GlobalTypes as dynamicpb
+// types. The data of an asset which holds one of the messages is then
+// unpacked into a *dynamicpb.Message, which every engine can read and
+// write. A file that is already registered, such as one compiled into the
+// binary or a well-known type, is kept as is. The imports of a file must
+// either be registered or be in the set.
+func RegisterSet(set *descriptorpb.FileDescriptorSet) error {
+	const op = "protoset.RegisterSet"
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto, len(set.GetFile()))
+	for _, f := range set.GetFile() {
+		files[f.GetName()] = f
+	}
+
+	for _, f := range set.GetFile() {
+		if err := register(f.GetName(), files, make(map[string]bool)); err != nil {
+			return errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithErr(err))
+		}
+	}
+
+	return nil
+}
+
+// register registers the file with the given path after its imports. The
+// paths being registered are tracked to detect import cycles.
+func register(path string, files map[string]*descriptorpb.FileDescriptorProto, visiting map[string]bool) error {
+	if _, err := protoregistry.GlobalFiles.FindFileByPath(path); err == nil {
+		return nil
+	}
+
+	f, ok := files[path]
+	if !ok {
+		return errors.E(errors.WithTextf("import %s is neither registered nor in the set", path))
+	}
+	if visiting[path] {
+		return errors.E(errors.WithTextf("import cycle at %s", path))
+	}
+	visiting[path] = true
+
+	for _, dep := range f.GetDependency() {
+		if err := register(dep, files, visiting); err != nil {
+			return err
+		}
+	}
+
+	fd, err := protodesc.NewFile(f, protoregistry.GlobalFiles)
+	if err != nil {
+		return errors.E(errors.WithTextf("build %s", path), errors.WithErr(err))
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		return errors.E(errors.WithTextf("register %s", path), errors.WithErr(err))
+	}
+
+	return registerTypes(fd.Messages(), fd.Enums())
+}
+
+func registerTypes(messages protoreflect.MessageDescriptors, enums protoreflect.EnumDescriptors) error {
+	for i := 0; i < enums.Len(); i++ {
+		if err := protoregistry.GlobalTypes.RegisterEnum(dynamicpb.NewEnumType(enums.Get(i))); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.IsMapEntry() {
+			continue
+		}
+		if err := protoregistry.GlobalTypes.RegisterMessage(dynamicpb.NewMessageType(md)); err != nil {
+			return err
+		}
+		if err := registerTypes(md.Messages(), md.Enums()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
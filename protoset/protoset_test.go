@@ -0,0 +1,80 @@
+package protoset_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/sudo-suhas/play-script-engine/protoset"
+)
+
+func TestRegister(t *testing.T) {
+	// Registering the set a second time keeps the registered files.
+	for i := 0; i < 2; i++ {
+		if err := protoset.Register("../sample/testdata/model.binpb"); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	for _, url := range []string{
+		"type.googleapis.com/example.assets.v1.Model",
+		"type.googleapis.com/example.assets.v1.Model.Version",
+	} {
+		mt, err := protoregistry.GlobalTypes.FindMessageByURL(url)
+		if err != nil {
+			t.Fatalf("FindMessageByURL(%s) error = %v", url, err)
+		}
+		if _, ok := mt.New().Interface().(*dynamicpb.Message); !ok {
+			t.Errorf("FindMessageByURL(%s) = %T, want a dynamic message", url, mt.New().Interface())
+		}
+	}
+	if _, err := protoregistry.GlobalTypes.FindEnumByName("example.assets.v1.Model.Flavor"); err != nil {
+		t.Errorf("FindEnumByName() error = %v", err)
+	}
+
+	err := protoset.Register("testdata/missing.binpb")
+	if errors.WhatKind(err) != errors.InvalidInput {
+		t.Errorf("Register() error = %v, want kind %v", err, errors.InvalidInput)
+	}
+}
+
+func TestRegisterSetImports(t *testing.T) {
+	file := func(name string, deps ...string) *descriptorpb.FileDescriptorProto {
+		return &descriptorpb.FileDescriptorProto{
+			Name:       proto.String(name),
+			Package:    proto.String("example.imports"),
+			Dependency: deps,
+			Syntax:     proto.String("proto3"),
+		}
+	}
+
+	cases := map[string]struct {
+		set  *descriptorpb.FileDescriptorSet
+		want string
+	}{
+		"missing": {
+			set:  &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file("a.proto", "b.proto")}},
+			want: "import b.proto is neither registered nor in the set",
+		},
+		"cycle": {
+			set: &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+				file("c.proto", "d.proto"), file("d.proto", "c.proto"),
+			}},
+			want: "import cycle at c.proto",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			err := protoset.RegisterSet(c.set)
+			if errors.WhatKind(err) != errors.InvalidInput || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("RegisterSet() error = %v, want kind %v and %q", err, errors.InvalidInput, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package protoutil
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// IsTimestamp reports whether the field, or the elements of the repeated
+// field, are google.protobuf.Timestamp messages.
+func IsTimestamp(fd protoreflect.FieldDescriptor) bool {
+	return fd.Message() != nil && fd.Message().FullName() == timestampName
+}
+
+// TimeOf reads the timestamp, which can be a dynamic message in the data
+// of a type registered by protoset.
+func TimeOf(m protoreflect.Message) time.Time {
+	fields := m.Descriptor().Fields()
+	secs, nanos := m.Get(fields.ByName("seconds")).Int(), m.Get(fields.ByName("nanos")).Int()
+	return time.Unix(secs, nanos).UTC()
+}
+
+// SetTime sets the timestamp, which can be a dynamic message, to the time.
+func SetTime(m protoreflect.Message, t time.Time) {
+	ts := timestamppb.New(t)
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(ts.Seconds))
+	m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(ts.Nanos))
+}
+
+// MapKey parses the string, such as the name of a property, as a key of
+// the map field.
+func MapKey(fd protoreflect.FieldDescriptor, key string) (protoreflect.MapKey, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = key
+	case protoreflect.BoolKind:
+		v, err = strconv.ParseBool(key)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var i int64
+		i, err = strconv.ParseInt(key, 10, 32)
+		v = int32(i)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err = strconv.ParseInt(key, 10, 64)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var u uint64
+		u, err = strconv.ParseUint(key, 10, 32)
+		v = uint32(u)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err = strconv.ParseUint(key, 10, 64)
+	}
+	if err != nil {
+		return protoreflect.MapKey{}, fmt.Errorf("invalid %s key", fd.Kind())
+	}
+	return protoreflect.ValueOf(v).MapKey(), nil
+}
@@ -0,0 +1,113 @@
+package protoutil_test
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+func TestIsTimestamp(t *testing.T) {
+	fields := (&asset.Asset{}).ProtoReflect().Descriptor().Fields()
+	for name, want := range map[protoreflect.Name]bool{
+		"create_time": true,
+		"labels":      false,
+		"owners":      false,
+		"name":        false,
+	} {
+		if got := protoutil.IsTimestamp(fields.ByName(name)); got != want {
+			t.Errorf("IsTimestamp(%s) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+// TestTime sets and reads a timestamp as a dynamic message, such as in
+// the data of a type registered by protoset.
+func TestTime(t *testing.T) {
+	want := time.Date(2022, time.March, 4, 5, 6, 7, 123_456_789, time.UTC)
+
+	m := dynamicpb.NewMessage((&timestamppb.Timestamp{}).ProtoReflect().Descriptor())
+	protoutil.SetTime(m, want)
+	if got := protoutil.TimeOf(m); !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("TimeOf() = %v, want %v", got, want)
+	}
+}
+
+func TestMapKey(t *testing.T) {
+	cases := []struct {
+		kind descriptorpb.FieldDescriptorProto_Type
+		key  string
+		want interface{}
+		err  string
+	}{
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_STRING, key: "team", want: "team"},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_BOOL, key: "true", want: true},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_INT32, key: "-7", want: int32(-7)},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_INT32, key: "2147483648", err: "invalid int32 key"},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_SINT64, key: "-9223372036854775808", want: int64(-9223372036854775808)},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_FIXED32, key: "4294967295", want: uint32(4294967295)},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_UINT64, key: "18446744073709551615", want: uint64(18446744073709551615)},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_UINT64, key: "-1", err: "invalid uint64 key"},
+		{kind: descriptorpb.FieldDescriptorProto_TYPE_BOOL, key: "yes", err: "invalid bool key"},
+	}
+	for _, c := range cases {
+		fd := mapField(t, c.kind).MapKey()
+		k, err := protoutil.MapKey(fd, c.key)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("MapKey(%s, %q) error = %v, want %s", fd.Kind(), c.key, err, c.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("MapKey(%s, %q) error = %v", fd.Kind(), c.key, err)
+			continue
+		}
+		if got := k.Interface(); got != c.want {
+			t.Errorf("MapKey(%s, %q) = %#v, want %#v", fd.Kind(), c.key, got, c.want)
+		}
+	}
+}
+
+// mapField returns a map field from keys of the kind to strings.
+func mapField(t *testing.T, kind descriptorpb.FieldDescriptorProto_Type) protoreflect.FieldDescriptor {
+	t.Helper()
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("map_key.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("M"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("m"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".test.M.MEntry"),
+				JsonName: proto.String("m"),
+			}},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("MEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("key"), Number: proto.Int32(1), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: kind.Enum(), JsonName: proto.String("key")},
+					{Name: proto.String("value"), Number: proto.Int32(2), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), JsonName: proto.String("value")},
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile() error = %v", err)
+	}
+	return fd.Messages().Get(0).Fields().Get(0)
+}
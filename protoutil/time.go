@@ -0,0 +1,22 @@
+// Package protoutil holds the helpers shared by the engines, and by
+// structmap, to work with proto messages through protoreflect.
+package protoutil
+
+import (
+	"math"
+	"time"
+)
+
+// UnixSeconds returns the time as the float64 number of seconds since the
+// Unix epoch, the form of timestamps in Lua.
+func UnixSeconds(t time.Time) float64 {
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+// FromUnixSeconds is the inverse of UnixSeconds. A float64 holds the
+// current times to about a microsecond, the digits past it are noise, so
+// the time is rounded to the microsecond.
+func FromUnixSeconds(secs float64) time.Time {
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(math.Round(frac*1e6))*1e3).UTC()
+}
@@ -0,0 +1,32 @@
+package protoutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+func TestUnixSeconds(t *testing.T) {
+	for _, c := range []struct {
+		in, want time.Time
+	}{
+		{
+			in:   time.Date(2022, time.March, 4, 5, 6, 7, 123_456_000, time.UTC),
+			want: time.Date(2022, time.March, 4, 5, 6, 7, 123_456_000, time.UTC),
+		},
+		// The nanoseconds are past the precision of a float64.
+		{
+			in:   time.Date(2022, time.March, 4, 5, 6, 7, 123_456_789, time.UTC),
+			want: time.Date(2022, time.March, 4, 5, 6, 7, 123_457_000, time.UTC),
+		},
+		{
+			in:   time.Date(1960, time.January, 1, 0, 0, 0, 500_000, time.UTC),
+			want: time.Date(1960, time.January, 1, 0, 0, 0, 500_000, time.UTC),
+		},
+	} {
+		if got := protoutil.FromUnixSeconds(protoutil.UnixSeconds(c.in)); !got.Equal(c.want) {
+			t.Errorf("FromUnixSeconds(UnixSeconds(%v)) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
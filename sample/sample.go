@@ -0,0 +1,29 @@
+// Package sample builds sample assets, one for each of the asset data types,
+// and generates random feature tables.
+package sample
+
+import (
+	"github.com/sudo-suhas/xgo/errors"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// Assets returns a sample asset for each of the asset data types, in the
+// order of their type names.
+func Assets() ([]*asset.Asset, error) {
+	const op = "sample.Assets"
+
+	builders := []func() (*asset.Asset, error){
+		Application, Bucket, Dashboard, FeatureTable, Group, Job, Model, Table, Topic, User,
+	}
+	assets := make([]*asset.Asset, len(builders))
+	for i, build := range builders {
+		a, err := build()
+		if err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		assets[i] = a
+	}
+
+	return assets, nil
+}
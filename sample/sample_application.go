@@ -0,0 +1,49 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Application() (*asset.Asset, error) {
+	const op = "sample.Application"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"replicas":  4,
+		"image":     "gcr.io/gofood/merchant-eta-service",
+		"endpoints": []interface{}{"/v1/eta", "/healthz"},
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("application attributes"), errors.WithErr(err))
+	}
+
+	application, err := anypb.New(&asset.Application{
+		Id:         "merchant-eta-service",
+		Version:    "v1.4.2",
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2022, time.June, 14, 6, 30, 0, 0, time.UTC)),
+		UpdateTime: timestamppb.New(time.Date(2022, time.September, 22, 3, 12, 45, 0, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("application data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:optimus:optimus-prod:application:merchant-eta-service",
+		Name:    "merchant-eta-service",
+		Service: "optimus",
+		Type:    "application",
+		Data:    application,
+		Lineage: &asset.Lineage{
+			Upstreams: []*asset.Resource{
+				{Urn: "urn:merlin:merlin-prod:model:sauron.merchant_eta", Service: "merlin", Type: "model"},
+			},
+		},
+	}, nil
+}
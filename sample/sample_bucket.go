@@ -0,0 +1,60 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Bucket() (*asset.Asset, error) {
+	const op = "sample.Bucket"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"versioning": true,
+		"lifecycle":  []interface{}{map[string]interface{}{"action": "Delete", "age_days": 90}},
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("bucket attributes"), errors.WithErr(err))
+	}
+
+	bucket, err := anypb.New(&asset.Bucket{
+		Location:    "ASIA-SOUTHEAST1",
+		StorageType: "STANDARD",
+		Blobs: []*asset.Blob{
+			{
+				Urn:  "urn:gcs:gofood-data:object:gofood-exports/orders/2022-09-21.parquet",
+				Name: "orders/2022-09-21.parquet",
+				Size: 734_003_200,
+				Owners: []*asset.Owner{
+					{Email: "sauron@mordor.io"},
+				},
+				ExpireTime: timestamppb.New(time.Date(2022, time.December, 20, 0, 0, 0, 0, time.UTC)),
+				CreateTime: timestamppb.New(time.Date(2022, time.September, 22, 1, 5, 33, 0, time.UTC)),
+			},
+			{
+				Urn:        "urn:gcs:gofood-data:object:gofood-exports/orders/2022-06-01.parquet",
+				Name:       "orders/2022-06-01.parquet",
+				Size:       512,
+				DeleteTime: timestamppb.New(time.Date(2022, time.August, 30, 0, 0, 0, 0, time.UTC)),
+			},
+		},
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2020, time.February, 29, 12, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("bucket data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:gcs:gofood-data:bucket:gofood-exports",
+		Name:    "gofood-exports",
+		Service: "gcs",
+		Type:    "bucket",
+		Data:    bucket,
+	}, nil
+}
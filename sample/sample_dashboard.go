@@ -0,0 +1,71 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Dashboard() (*asset.Asset, error) {
+	const op = "sample.Dashboard"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"id":         42,
+		"collection": "GoFood",
+		"archived":   false,
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("dashboard attributes"), errors.WithErr(err))
+	}
+
+	const dashboardURN = "urn:metabase:metabase-prod:dashboard:42"
+	dashboard, err := anypb.New(&asset.Dashboard{
+		Charts: []*asset.Chart{
+			{
+				Urn:             "urn:metabase:metabase-prod:card:301",
+				Name:            "Orders per hour",
+				Type:            "line",
+				Source:          "metabase",
+				Url:             "https://metabase.yonkou.io/question/301",
+				RawQuery:        "SELECT TIMESTAMP_TRUNC(event_timestamp, HOUR) AS hour, COUNT(*) FROM orders.order_log GROUP BY 1",
+				DataSource:      "bigquery",
+				DashboardUrn:    dashboardURN,
+				DashboardSource: "metabase",
+				CreateTime:      timestamppb.New(time.Date(2022, time.January, 5, 10, 30, 0, 0, time.UTC)),
+			},
+			{
+				Urn:             "urn:metabase:metabase-prod:card:302",
+				Name:            "Cancellations by reason",
+				Type:            "bar",
+				Source:          "metabase",
+				Description:     "Cancelled orders in the last 7 days",
+				DataSource:      "bigquery",
+				DashboardUrn:    dashboardURN,
+				DashboardSource: "metabase",
+			},
+		},
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2022, time.January, 5, 10, 0, 0, 0, time.UTC)),
+		UpdateTime: timestamppb.New(time.Date(2022, time.August, 30, 17, 45, 12, 0, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("dashboard data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     dashboardURN,
+		Name:    "GoFood Orders",
+		Service: "metabase",
+		Type:    "dashboard",
+		Url:     "https://metabase.yonkou.io/dashboard/42",
+		Data:    dashboard,
+		Owners: []*asset.Owner{
+			{Urn: "urn:shield:user:saruman", Email: "saruman@isengard.io"},
+		},
+	}, nil
+}
@@ -1,6 +1,7 @@
 package sample
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/sudo-suhas/xgo/errors"
@@ -27,7 +28,7 @@ func FeatureTable() (*asset.Asset, error) {
 			{Name: "merchant_avg_dispatch_arrival_time_10m", DataType: "FLOAT"},
 			{Name: "ongoing_accepted_orders", DataType: "INT64"},
 		},
-		CreateTime: timestamppb.New(time.Date(2022, time.September, 19, 22, 42, 0o4, 0, time.UTC)),
+		CreateTime: timestamppb.New(time.Date(2022, time.September, 19, 22, 42, 0o4, 123_456_789, time.UTC)),
 		UpdateTime: timestamppb.New(time.Date(2022, time.September, 21, 13, 23, 0o2, 0, time.UTC)),
 	})
 	if err != nil {
@@ -51,3 +52,40 @@ func FeatureTable() (*asset.Asset, error) {
 		},
 	}, nil
 }
+
+// FeatureTableWithFeatures returns the sample feature table with n
+// features. The first features are those of FeatureTable, so that the
+// entity names mapped by the scripts apply to them, and the rest are named
+// feature_<i>.
+func FeatureTableWithFeatures(n int) (*asset.Asset, error) {
+	const op = "sample.FeatureTableWithFeatures"
+
+	a, err := FeatureTable()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	var data asset.FeatureTable
+	if err := a.Data.UnmarshalTo(&data); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("feature table data"), errors.WithErr(err))
+	}
+
+	features := make([]*asset.Feature, n)
+	for i := range features {
+		if i < len(data.Features) {
+			features[i] = data.Features[i]
+			continue
+		}
+		features[i] = &asset.Feature{
+			Name:     fmt.Sprintf("feature_%d", i),
+			DataType: data.Features[i%len(data.Features)].DataType,
+		}
+	}
+	data.Features = features
+
+	if a.Data, err = anypb.New(&data); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("feature table data"), errors.WithErr(err))
+	}
+
+	return a, nil
+}
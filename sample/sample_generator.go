@@ -0,0 +1,258 @@
+package sample
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+// GenOptions bound the shape of the assets built by a Generator. A field
+// that is zero uses the default given for it.
+type GenOptions struct {
+	// MaxEntities in the feature table, 3 by default.
+	MaxEntities int
+	// MaxFeatures in the feature table, 50 by default.
+	MaxFeatures int
+	// MaxOwners of the asset, 3 by default.
+	MaxOwners int
+	// MaxLabels of the asset and of each entity, 5 by default.
+	MaxLabels int
+	// MaxResources in each of the upstreams and downstreams of the lineage,
+	// 3 by default.
+	MaxResources int
+}
+
+// Generator builds randomised feature table assets. The assets built by
+// Generators with the same seed and options are the same, so that a
+// failure can be reproduced.
+//
+// Besides varying the number of entities, features, owners, labels and
+// lineage resources, the assets vary in shapes that scripts tend to trip
+// on: nil vs empty lineage and labels, missing timestamps and events and
+// non-ASCII names.
+//
+// A Generator is not safe for concurrent use.
+type Generator struct {
+	rnd  *rand.Rand
+	opts GenOptions
+}
+
+// NewGenerator returns a Generator seeded with seed.
+func NewGenerator(seed int64, opts GenOptions) *Generator {
+	if opts.MaxEntities == 0 {
+		opts.MaxEntities = 3
+	}
+	if opts.MaxFeatures == 0 {
+		opts.MaxFeatures = 50
+	}
+	if opts.MaxOwners == 0 {
+		opts.MaxOwners = 3
+	}
+	if opts.MaxLabels == 0 {
+		opts.MaxLabels = 5
+	}
+	if opts.MaxResources == 0 {
+		opts.MaxResources = 3
+	}
+
+	return &Generator{
+		rnd:  rand.New(rand.NewSource(seed)), //nolint:gosec // Reproducible, not secure, randomness is wanted.
+		opts: opts,
+	}
+}
+
+// Assets returns the next n assets.
+func (g *Generator) Assets(n int) ([]*asset.Asset, error) {
+	const op = "sample.Generator.Assets"
+
+	assets := make([]*asset.Asset, n)
+	for i := range assets {
+		a, err := g.Asset()
+		if err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		assets[i] = a
+	}
+	return assets, nil
+}
+
+// Asset returns the next asset.
+func (g *Generator) Asset() (*asset.Asset, error) {
+	const op = "sample.Generator.Asset"
+
+	data, err := anypb.New(g.featureTable())
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("feature table data"), errors.WithErr(err))
+	}
+
+	name := g.name()
+	return &asset.Asset{
+		Urn:         "urn:caramlstore:" + g.word() + ":feature_table:" + name,
+		Name:        name,
+		Service:     "caramlstore",
+		Type:        "feature_table",
+		Description: g.maybe(strings.Join([]string{g.word(), g.word(), g.word()}, " ")),
+		Data:        data,
+		Owners:      g.owners(),
+		Lineage:     g.lineage(),
+		Labels:      g.labels(),
+		Event:       g.event(),
+		CreateTime:  g.timestamp(),
+		UpdateTime:  g.timestamp(),
+	}, nil
+}
+
+func (g *Generator) featureTable() *asset.FeatureTable {
+	var entities []*asset.FeatureTable_Entity
+	for i, n := 0, g.rnd.Intn(g.opts.MaxEntities+1); i < n; i++ {
+		name := g.name()
+		entities = append(entities, &asset.FeatureTable_Entity{
+			Name:     name,
+			JoinKeys: []string{name},
+			Labels:   g.labels(),
+		})
+	}
+
+	var features []*asset.Feature
+	for i, n := 0, g.rnd.Intn(g.opts.MaxFeatures+1); i < n; i++ {
+		f := asset.Feature{
+			Name:     g.name(),
+			DataType: dataTypes[g.rnd.Intn(len(dataTypes))],
+		}
+		if len(entities) != 0 && g.rnd.Intn(2) == 0 {
+			f.EntityName = entities[g.rnd.Intn(len(entities))].Name
+		}
+		features = append(features, &f)
+	}
+
+	return &asset.FeatureTable{
+		Namespace:  g.word(),
+		Entities:   entities,
+		Features:   features,
+		CreateTime: g.timestamp(),
+		UpdateTime: g.timestamp(),
+	}
+}
+
+func (g *Generator) owners() []*asset.Owner {
+	var owners []*asset.Owner
+	for i, n := 0, g.rnd.Intn(g.opts.MaxOwners+1); i < n; i++ {
+		user := g.word()
+		owners = append(owners, &asset.Owner{
+			Urn:   "urn:user:" + user,
+			Name:  user,
+			Role:  g.maybe("owner"),
+			Email: g.maybe(user + "@example.com"),
+		})
+	}
+	return owners
+}
+
+// lineage is nil, empty or has upstreams and downstreams each of which may
+// be nil, empty or not.
+func (g *Generator) lineage() *asset.Lineage {
+	switch g.rnd.Intn(3) {
+	case 0:
+		return nil
+	case 1:
+		return &asset.Lineage{}
+	}
+
+	return &asset.Lineage{
+		Upstreams:   g.resources(),
+		Downstreams: g.resources(),
+	}
+}
+
+func (g *Generator) resources() []*asset.Resource {
+	n := g.rnd.Intn(g.opts.MaxResources + 2)
+	if n == 0 {
+		return nil
+	}
+
+	resources := make([]*asset.Resource, n-1)
+	for i := range resources {
+		name := g.name()
+		resources[i] = &asset.Resource{
+			Urn:     "urn:kafka:" + g.word() + ":topic:" + name,
+			Name:    g.maybe(name),
+			Service: "kafka",
+			Type:    "topic",
+		}
+	}
+	return resources
+}
+
+// labels are nil, empty or not.
+func (g *Generator) labels() map[string]string {
+	n := g.rnd.Intn(g.opts.MaxLabels + 2)
+	if n == 0 {
+		return nil
+	}
+
+	labels := make(map[string]string, n-1)
+	for i := 0; i < n-1; i++ {
+		labels[g.word()] = g.word()
+	}
+	return labels
+}
+
+func (g *Generator) event() *asset.Event {
+	if g.rnd.Intn(2) == 0 {
+		return nil
+	}
+
+	return &asset.Event{
+		Timestamp:   g.timestamp(),
+		Action:      actions[g.rnd.Intn(len(actions))],
+		Description: g.maybe(g.word()),
+	}
+}
+
+// timestamp is missing or within a year after 2022-01-01.
+func (g *Generator) timestamp() *timestamppb.Timestamp {
+	if g.rnd.Intn(4) == 0 {
+		return nil
+	}
+
+	t := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return timestamppb.New(t.Add(time.Duration(g.rnd.Int63n(int64(365 * 24 * time.Hour)))))
+}
+
+// name joins words with an underscore and a suffix to make it unlikely for
+// names in an asset to collide.
+func (g *Generator) name() string {
+	return fmt.Sprintf("%s_%s_%d", g.word(), g.word(), g.rnd.Intn(1000))
+}
+
+func (g *Generator) word() string {
+	return words[g.rnd.Intn(len(words))]
+}
+
+// maybe returns s or, half of the time, the empty string.
+func (g *Generator) maybe(s string) string {
+	if g.rnd.Intn(2) == 0 {
+		return ""
+	}
+	return s
+}
+
+var (
+	// words include non-ASCII ones, in different scripts and of different
+	// byte lengths, to catch scripts that assume a character is a byte.
+	words = []string{
+		"merchant", "orders", "dispatch", "arrival", "ongoing", "avg",
+		"customer", "driver", "sauron", "gofood", "payment", "latency",
+		"café", "naïve", "straße", "données", "ünïcödé",
+		"заказ", "τιμή", "注文", "配達", "주문", "🚀", "📦",
+	}
+	dataTypes = []string{"INT64", "FLOAT", "DOUBLE", "STRING", "BOOL", "BYTES"}
+	actions   = []string{"create", "update", "delete"}
+)
@@ -0,0 +1,106 @@
+package sample_test
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+)
+
+func TestGeneratorSeed(t *testing.T) {
+	got, err := sample.NewGenerator(7, sample.GenOptions{}).Assets(50)
+	if err != nil {
+		t.Fatalf("Assets() error = %v", err)
+	}
+	want, err := sample.NewGenerator(7, sample.GenOptions{}).Assets(50)
+	if err != nil {
+		t.Fatalf("Assets() error = %v", err)
+	}
+	for i := range got {
+		if !assetEqual(t, got[i], want[i]) {
+			t.Errorf("asset %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	other, err := sample.NewGenerator(8, sample.GenOptions{}).Asset()
+	if err != nil {
+		t.Fatalf("Asset() error = %v", err)
+	}
+	if assetEqual(t, other, got[0]) {
+		t.Errorf("Asset() = %v for another seed, want a different asset", other)
+	}
+}
+
+func TestGeneratorShapes(t *testing.T) {
+	opts := sample.GenOptions{MaxOwners: 2, MaxLabels: 3}
+	assets, err := sample.NewGenerator(1, opts).Assets(200)
+	if err != nil {
+		t.Fatalf("Assets() error = %v", err)
+	}
+
+	shapes := map[string]bool{}
+	for _, a := range assets {
+		switch {
+		case a.Lineage == nil:
+			shapes["nil lineage"] = true
+		case a.Lineage.Upstreams == nil && a.Lineage.Downstreams == nil:
+			shapes["empty lineage"] = true
+		}
+		switch {
+		case a.Labels == nil:
+			shapes["nil labels"] = true
+		case len(a.Labels) == 0:
+			shapes["empty labels"] = true
+		}
+		if a.CreateTime == nil {
+			shapes["missing timestamp"] = true
+		}
+		if a.Event == nil {
+			shapes["missing event"] = true
+		}
+		if utf8.RuneCountInString(a.Name) != len(a.Name) {
+			shapes["non-ASCII name"] = true
+		}
+
+		if len(a.Owners) > opts.MaxOwners {
+			t.Errorf("asset %s has %d owners, want at most %d", a.Urn, len(a.Owners), opts.MaxOwners)
+		}
+		if len(a.Labels) > opts.MaxLabels {
+			t.Errorf("asset %s has %d labels, want at most %d", a.Urn, len(a.Labels), opts.MaxLabels)
+		}
+	}
+
+	for _, shape := range []string{
+		"nil lineage", "empty lineage", "nil labels", "empty labels",
+		"missing timestamp", "missing event", "non-ASCII name",
+	} {
+		if !shapes[shape] {
+			t.Errorf("no asset with a %s in %d assets", shape, len(assets))
+		}
+	}
+}
+
+// assetEqual compares the assets and their unpacked data, whose bytes
+// depend on the order of the map entries.
+func assetEqual(t *testing.T, a, b *asset.Asset) bool {
+	t.Helper()
+
+	aData, err := a.Data.UnmarshalNew()
+	if err != nil {
+		t.Fatalf("UnmarshalNew() error = %v", err)
+	}
+	bData, err := b.Data.UnmarshalNew()
+	if err != nil {
+		t.Fatalf("UnmarshalNew() error = %v", err)
+	}
+	if !proto.Equal(aData, bData) {
+		return false
+	}
+
+	a, b = proto.Clone(a).(*asset.Asset), proto.Clone(b).(*asset.Asset)
+	a.Data, b.Data = nil, nil
+	return proto.Equal(a, b)
+}
@@ -0,0 +1,42 @@
+package sample
+
+import (
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Group() (*asset.Asset, error) {
+	const op = "sample.Group"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"slack_channel": "#fellowship",
+		"size_limit":    9,
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("group attributes"), errors.WithErr(err))
+	}
+
+	group, err := anypb.New(&asset.Group{
+		Email: "fellowship@middle-earth.io",
+		Members: []*asset.Member{
+			{Urn: "urn:shield:user:frodo", Role: "ring-bearer"},
+			{Urn: "urn:shield:user:gandalf", Role: "admin"},
+			{Urn: "urn:shield:user:samwise"},
+		},
+		Attributes: attributes,
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("group data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:shield:group:fellowship",
+		Name:    "The Fellowship of the Ring",
+		Service: "shield",
+		Type:    "group",
+		Data:    group,
+	}, nil
+}
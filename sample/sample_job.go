@@ -0,0 +1,58 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Job() (*asset.Asset, error) {
+	const op = "sample.Job"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"version":    2,
+		"project":    "gofood-data",
+		"namespace":  "orders",
+		"owner":      "sauron@mordor.io",
+		"start_date": "2021-03-02",
+		"interval":   "0 2 * * *",
+		"task": map[string]interface{}{
+			"name":   "bq2bq",
+			"config": map[string]interface{}{"LOAD_METHOD": "REPLACE", "SQL_TYPE": "STANDARD"},
+		},
+		"dependencies": []interface{}{"gofood-data.orders.order_log"},
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("job attributes"), errors.WithErr(err))
+	}
+
+	job, err := anypb.New(&asset.Job{
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2021, time.March, 2, 9, 0, 0, 0, time.UTC)),
+		UpdateTime: timestamppb.New(time.Date(2022, time.September, 1, 2, 0, 0, 500_000_000, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("job data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:optimus:optimus-prod:job:orders.daily_order_summary",
+		Name:    "daily_order_summary",
+		Service: "optimus",
+		Type:    "job",
+		Data:    job,
+		Lineage: &asset.Lineage{
+			Upstreams: []*asset.Resource{
+				{Urn: "urn:bigquery:gofood-data:table:gofood-data:orders.order_log", Service: "bigquery", Type: "table"},
+			},
+			Downstreams: []*asset.Resource{
+				{Urn: "urn:bigquery:gofood-data:table:gofood-data:orders.daily_order_summary", Service: "bigquery", Type: "table"},
+			},
+		},
+	}, nil
+}
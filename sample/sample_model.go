@@ -0,0 +1,80 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Model() (*asset.Asset, error) {
+	const op = "sample.Model"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"experiment_id": "1234",
+		"metrics":       map[string]interface{}{"rmse": 3.1, "mae": 2.4},
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("model attributes"), errors.WithErr(err))
+	}
+
+	signature := &asset.ModelSignature{
+		Inputs: []*asset.ModelParameter{
+			{Name: "distance_km", DataType: "float32", Shape: []int64{-1}},
+			{Name: "items", DataType: "int64", Shape: []int64{-1, 16}},
+		},
+		Outputs: []*asset.ModelParameter{
+			{Name: "eta_minutes", DataType: "float32", Shape: []int64{-1}},
+		},
+	}
+
+	model, err := anypb.New(&asset.Model{
+		Namespace: "sauron",
+		Flavor:    "sklearn",
+		Algorithm: "gradient_boosting",
+		Status:    "production",
+		Version:   "2",
+		Versions: []*asset.ModelVersion{
+			{
+				Version:    "1",
+				Status:     "archived",
+				Signature:  signature,
+				CreateTime: timestamppb.New(time.Date(2022, time.May, 2, 8, 0, 0, 0, time.UTC)),
+			},
+			{
+				Version:    "2",
+				Status:     "production",
+				Signature:  signature,
+				Labels:     map[string]string{"approved_by": "gandalf"},
+				Attributes: attributes,
+				CreateTime: timestamppb.New(time.Date(2022, time.September, 21, 13, 23, 0o2, 250_000_000, time.UTC)),
+			},
+		},
+		CreateTime: timestamppb.New(time.Date(2022, time.May, 2, 8, 0, 0, 0, time.UTC)),
+		UpdateTime: timestamppb.New(time.Date(2022, time.September, 21, 13, 23, 0o2, 250_000_000, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("model data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:merlin:merlin-prod:model:sauron.merchant_eta",
+		Name:    "merchant_eta",
+		Service: "merlin",
+		Type:    "model",
+		Data:    model,
+		Lineage: &asset.Lineage{
+			Upstreams: []*asset.Resource{
+				{
+					Urn:     "urn:caramlstore:test-caramlstore:feature_table:avg_dispatch_arrival_time_10_mins",
+					Service: "caramlstore",
+					Type:    "feature_table",
+				},
+			},
+		},
+	}, nil
+}
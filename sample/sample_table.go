@@ -0,0 +1,100 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Table() (*asset.Asset, error) {
+	const op = "sample.Table"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"dataset":           "orders",
+		"project":           "gofood-data",
+		"partition":         map[string]interface{}{"field": "event_timestamp", "type": "DAY"},
+		"clustering_fields": []interface{}{"merchant_uuid", "customer_uuid"},
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("table attributes"), errors.WithErr(err))
+	}
+
+	rows, err := structpb.NewList([]interface{}{
+		[]interface{}{"9a4c", "b1f2", 3, 245.5, "2022-09-20"},
+		[]interface{}{"71de", "c0a9", 1, 82.25, "2022-09-21"},
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("table preview"), errors.WithErr(err))
+	}
+
+	table, err := anypb.New(&asset.Table{
+		Profile: &asset.TableProfile{
+			TotalRows:      1_238_455_021,
+			PartitionKey:   "event_timestamp",
+			PartitionValue: "2022-09-21",
+			UsageCount:     4312,
+			CommonJoins: []*asset.TableCommonJoin{
+				{
+					Urn:        "urn:bigquery:gofood-data:table:gofood-data:merchants.merchant_profile",
+					Count:      1021,
+					Conditions: []string{"ON target.merchant_uuid = source.merchant_uuid"},
+				},
+			},
+			Filters: []*asset.TableFilter{
+				{Condition: "WHERE event_timestamp > CURRENT_DATE()", Count: 2890},
+			},
+		},
+		Columns: []*asset.Column{
+			{Name: "order_uuid", DataType: "STRING", Description: "unique identifier of the order", Length: 36},
+			{Name: "merchant_uuid", DataType: "STRING", IsNullable: true, Length: 36},
+			{
+				Name:     "items",
+				DataType: "RECORD",
+				Columns: []*asset.Column{
+					{Name: "quantity", DataType: "INT64"},
+					{Name: "price", DataType: "FLOAT64", Profile: &asset.ColumnProfile{
+						Min: "0.5", Max: "1250", Avg: 42.75, Med: 18, Unique: 3012, Count: 9_032_114, Top: "15",
+					}},
+				},
+			},
+			{Name: "event_timestamp", DataType: "TIMESTAMP"},
+		},
+		Preview: &asset.TablePreview{
+			Fields: []string{"order_uuid", "merchant_uuid", "items.quantity", "items.price", "event_date"},
+			Rows:   rows,
+		},
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2021, time.March, 2, 8, 15, 42, 123_000_000, time.UTC)),
+		UpdateTime: timestamppb.New(time.Date(2022, time.September, 21, 13, 23, 0o2, 0, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("table data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:         "urn:bigquery:gofood-data:table:gofood-data:orders.order_log",
+		Name:        "order_log",
+		Service:     "bigquery",
+		Type:        "table",
+		Description: "Log of the orders placed on GoFood",
+		Data:        table,
+		Owners: []*asset.Owner{
+			{Urn: "urn:shield:user:sauron", Name: "Sauron", Role: "owner", Email: "sauron@mordor.io"},
+		},
+		Lineage: &asset.Lineage{
+			Upstreams: []*asset.Resource{
+				{
+					Urn:     "urn:kafka:int-dagstream-kafka.yonkou.io:topic:GO_FOOD-order-log",
+					Service: "kafka",
+					Type:    "topic",
+				},
+			},
+		},
+		Labels: map[string]string{"team": "gofood-data"},
+	}, nil
+}
@@ -0,0 +1,60 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func Topic() (*asset.Asset, error) {
+	const op = "sample.Topic"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"retention_ms":       604_800_000,
+		"cleanup_policy":     "delete",
+		"replication_factor": 3,
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("topic attributes"), errors.WithErr(err))
+	}
+
+	topic, err := anypb.New(&asset.Topic{
+		Profile: &asset.TopicProfile{Throughput: "5MB/s", NumberOfPartitions: 24},
+		Schema: &asset.TopicSchema{
+			Format: "protobuf",
+			Url:    "https://stencil.yonkou.io/v1beta1/namespaces/gofood/schemas/order-log",
+		},
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2020, time.November, 11, 4, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("topic data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:kafka:int-dagstream-kafka.yonkou.io:topic:GO_FOOD-order-log",
+		Name:    "GO_FOOD-order-log",
+		Service: "kafka",
+		Type:    "topic",
+		Data:    topic,
+		Lineage: &asset.Lineage{
+			Downstreams: []*asset.Resource{
+				{
+					Urn:     "urn:bigquery:gofood-data:table:gofood-data:orders.order_log",
+					Service: "bigquery",
+					Type:    "table",
+				},
+			},
+		},
+		Event: &asset.Event{
+			Timestamp:   timestamppb.New(time.Date(2022, time.September, 21, 13, 23, 0o2, 0, time.UTC)),
+			Action:      "update",
+			Description: "partitions increased from 12 to 24",
+		},
+	}, nil
+}
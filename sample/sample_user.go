@@ -0,0 +1,56 @@
+package sample
+
+import (
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+)
+
+func User() (*asset.Asset, error) {
+	const op = "sample.User"
+
+	attributes, err := structpb.NewStruct(map[string]interface{}{
+		"org_unit_path": "/engineering/data",
+		"is_admin":      false,
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("user attributes"), errors.WithErr(err))
+	}
+
+	user, err := anypb.New(&asset.User{
+		Email:        "frodo.baggins@shire.io",
+		Username:     "frodo",
+		FirstName:    "Frodo",
+		LastName:     "Baggins",
+		FullName:     "Frodo Baggins",
+		DisplayName:  "Mr. Underhill",
+		Title:        "Ring-bearer",
+		Status:       "active",
+		ManagerEmail: "gandalf@valinor.io",
+		Profiles: []*asset.Profile{
+			{Id: "frodo-b", Platform: "github", Url: "https://github.com/frodo-b"},
+		},
+		Memberships: []*asset.Membership{
+			{GroupUrn: "urn:shield:group:fellowship", Role: []string{"member", "ring-bearer"}},
+		},
+		Attributes: attributes,
+		CreateTime: timestamppb.New(time.Date(2019, time.September, 22, 0, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithText("user data"), errors.WithErr(err))
+	}
+
+	return &asset.Asset{
+		Urn:     "urn:shield:user:frodo",
+		Name:    "Frodo Baggins",
+		Service: "shield",
+		Type:    "user",
+		Data:    user,
+		Labels:  map[string]string{"location": "the shire"},
+	}, nil
+}
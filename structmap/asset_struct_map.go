@@ -1,8 +1,6 @@
 package structmap
 
 import (
-	"encoding/json"
-
 	"github.com/sudo-suhas/xgo/errors"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
@@ -13,6 +11,9 @@ import (
 type AssetWrapper struct {
 	*asset.Asset
 	UnmarshaledData proto.Message
+	// typeURL is the type URL of the data, which is kept when the data is
+	// packed again.
+	typeURL string
 }
 
 func NewAssetWrapper(a *asset.Asset) (*AssetWrapper, error) {
@@ -26,24 +27,21 @@ func NewAssetWrapper(a *asset.Asset) (*AssetWrapper, error) {
 	return &AssetWrapper{
 		Asset:           a,
 		UnmarshaledData: data,
+		typeURL:         a.Data.GetTypeUrl(),
 	}, nil
 }
 
-func (w *AssetWrapper) Encode() map[string]interface{} {
-	m := AsMap(w.Asset)
-	m["data"] = AsMap(w.UnmarshaledData)
-	return m
-}
-
-func (w *AssetWrapper) EncodeWithoutTypes() (map[string]interface{}, error) {
-	const op = "assetWrapper.EncodeWithoutTypes"
+// Encode returns the asset as a map, in the form documented on Encode,
+// with the data unpacked into the map of the message it holds.
+func (w *AssetWrapper) Encode(f Format) (map[string]interface{}, error) {
+	const op = "assetWrapper.Encode"
 
-	m, err := mapWithoutTypes(w.Asset)
+	m, err := Encode(w.Asset, f)
 	if err != nil {
 		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	m["data"], err = mapWithoutTypes(w.UnmarshaledData)
+	m["data"], err = Encode(w.UnmarshaledData, f)
 	if err != nil {
 		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
@@ -51,37 +49,29 @@ func (w *AssetWrapper) EncodeWithoutTypes() (map[string]interface{}, error) {
 	return m, nil
 }
 
+// OverwriteWith replaces the asset with the one decoded from the map, which
+// is expected to be in the form returned by Encode.
 func (w *AssetWrapper) OverwriteWith(m map[string]interface{}) error {
 	const op = "assetWrapper.OverwriteWith"
 
-	if err := AsStruct(m["data"], &w.UnmarshaledData); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("decode data map"), errors.WithErr(err))
+	if err := decode(m["data"], w.UnmarshaledData, "data"); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
-	delete(m, "data")
-	if err := AsStruct(m, w.Asset); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("decode asset map"), errors.WithErr(err))
+	rest := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k != "data" {
+			rest[k] = v
+		}
 	}
-
-	var err error
-	if w.Data, err = anypb.New(w.UnmarshaledData); err != nil {
+	if err := decode(rest, w.Asset, ""); err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
-	return nil
-}
-
-func mapWithoutTypes(v interface{}) (map[string]interface{}, error) {
-	const op = "tengo.map"
 
-	data, err := json.Marshal(v)
+	b, err := proto.Marshal(w.UnmarshaledData)
 	if err != nil {
-		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
-	}
-
-	var res map[string]interface{}
-	if err := json.Unmarshal(data, &res); err != nil {
-		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
-
-	return res, nil
+	w.Data = &anypb.Any{TypeUrl: w.typeURL, Value: b}
+	return nil
 }
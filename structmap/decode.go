@@ -0,0 +1,682 @@
+package structmap
+
+import (
+	"encoding/base64"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+// Decode resets the message and sets its fields from the map v, which is
+// expected to be in the form returned by Encode. The value of each field is
+// decoded as per its descriptor, which smooths over the differences in how
+// the engines represent values:
+//
+//   - Numbers of any Go type, and *big.Ints, are converted to the declared
+//     kind of the field, if they can be without losing precision.
+//   - Enums can also be given by number.
+//   - Timestamps can be given in any of the TimeFormats. A number of
+//     seconds, or a time with millisecond precision, of a timestamp of the
+//     message before it is reset is decoded as that timestamp, so that one
+//     the engine rounded is kept.
+//   - Durations can also be given as formatted by time.Duration, such as
+//     "1m30s".
+//   - A repeated field can also be given as an empty map, which is what an
+//     empty Lua table or JavaScript object becomes, and a map field as an
+//     empty list.
+//   - A nil value leaves the field unset.
+//
+// A value that does not fit the field, or a key that is not a field of the
+// message, is an error which reports the path of the field, for example
+// "data.features[2].entity_name: expected string, got number".
+func Decode(v interface{}, m proto.Message) error {
+	const op = "structmap.Decode"
+
+	if err := decode(v, m, ""); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return nil
+}
+
+// decode is Decode for a message at the given path.
+func decode(v interface{}, m proto.Message, path string) error {
+	d := decoder{times: make(map[interface{}]time.Time)}
+	d.keepTimes(m.ProtoReflect())
+
+	proto.Reset(m)
+	return d.decodeMessage(v, m.ProtoReflect(), path)
+}
+
+// decoder decodes a map into a message. times holds the timestamps of the
+// message before it was reset, by the forms in which an engine loses their
+// precision: the float64 of TimeUnix and the milliseconds of a JavaScript
+// Date. A timestamp in one of these forms is decoded as the timestamp it
+// was encoded from, so that one the script did not change is kept as it
+// was rather than rounded.
+type decoder struct {
+	times map[interface{}]time.Time
+}
+
+// unixMilli is the key of a timestamp by its milliseconds in times.
+type unixMilli int64
+
+// keepTimes adds the timestamps of the message, and of the messages in its
+// fields and Any fields, to times. A form shared by different timestamps is ambiguous, it
+// maps to the zero time which is not used.
+func (d *decoder) keepTimes(m protoreflect.Message) {
+	keep := func(v protoreflect.Value) {
+		t := protoutil.TimeOf(v.Message())
+		for _, k := range []interface{}{protoutil.UnixSeconds(t), unixMilli(t.UnixMilli())} {
+			if kt, ok := d.times[k]; ok && !kt.Equal(t) {
+				t = time.Time{}
+			}
+			d.times[k] = t
+		}
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil {
+			return true
+		}
+
+		each := func(v protoreflect.Value) {
+			if protoutil.IsTimestamp(fd) {
+				keep(v)
+				return
+			}
+			if a, ok := v.Message().Interface().(*anypb.Any); ok {
+				if inner, err := a.UnmarshalNew(); err == nil {
+					d.keepTimes(inner.ProtoReflect())
+				}
+				return
+			}
+			d.keepTimes(v.Message())
+		}
+		switch {
+		case fd.IsList():
+			for l, i := v.List(), 0; i < l.Len(); i++ {
+				each(l.Get(i))
+			}
+		case fd.IsMap():
+			if fd.MapValue().Message() == nil {
+				return true
+			}
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				if protoutil.IsTimestamp(fd.MapValue()) {
+					keep(v)
+				} else {
+					d.keepTimes(v.Message())
+				}
+				return true
+			})
+		default:
+			each(v)
+		}
+		return true
+	})
+}
+
+// keptTime returns the timestamp kept for the form k, if there is one.
+func (d *decoder) keptTime(k interface{}) (time.Time, bool) {
+	t, ok := d.times[k]
+	return t, ok && !t.IsZero()
+}
+
+func (d *decoder) decodeMessage(v interface{}, m protoreflect.Message, path string) error {
+	mv, ok := v.(map[string]interface{})
+	if !ok {
+		return mismatch(path, "object", v)
+	}
+
+	fields := m.Descriptor().Fields()
+	for _, name := range sortedKeys(mv) {
+		fv, fpath := mv[name], fieldPath(path, name)
+
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			return pathError(fpath, "unknown field of %s", m.Descriptor().FullName())
+		}
+		if fv == nil {
+			continue
+		}
+
+		if err := d.decodeField(fv, m, fd, fpath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *decoder) decodeField(v interface{}, m protoreflect.Message, fd protoreflect.FieldDescriptor, path string) error {
+	switch {
+	case fd.IsList():
+		lv, ok := asList(v)
+		if !ok {
+			return mismatch(path, "list", v)
+		}
+
+		l := m.Mutable(fd).List()
+		for i, ev := range lv {
+			e, err := d.decodeSingular(ev, fd, l.NewElement, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return err
+			}
+			l.Append(e)
+		}
+		return nil
+
+	case fd.IsMap():
+		mv, ok := v.(map[string]interface{})
+		if lv, isList := v.([]interface{}); isList && len(lv) == 0 {
+			mv, ok = nil, true
+		}
+		if !ok {
+			return mismatch(path, "object", v)
+		}
+
+		pm := m.Mutable(fd).Map()
+		for _, k := range sortedKeys(mv) {
+			ev, epath := mv[k], path+"["+strconv.Quote(k)+"]"
+
+			key, err := decodeMapKey(k, fd.MapKey(), epath)
+			if err != nil {
+				return err
+			}
+
+			val, err := d.decodeSingular(ev, fd.MapValue(), pm.NewValue, epath)
+			if err != nil {
+				return err
+			}
+			pm.Set(key, val)
+		}
+		return nil
+
+	default:
+		val, err := d.decodeSingular(v, fd, func() protoreflect.Value { return m.NewField(fd) }, path)
+		if err != nil {
+			return err
+		}
+
+		m.Set(fd, val)
+		return nil
+	}
+}
+
+// decodeSingular decodes a single value of the field. newMessage returns
+// an empty value to decode a message into.
+func (d *decoder) decodeSingular(v interface{}, fd protoreflect.FieldDescriptor, newMessage func() protoreflect.Value, path string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, ok := v.(bool)
+		if !ok {
+			return protoreflect.Value{}, mismatch(path, "bool", v)
+		}
+		return protoreflect.ValueOfBool(b), nil
+
+	case protoreflect.StringKind:
+		s, ok := v.(string)
+		if !ok {
+			return protoreflect.Value{}, mismatch(path, "string", v)
+		}
+		return protoreflect.ValueOfString(s), nil
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := toInt(v, math.MinInt32, math.MaxInt32, path)
+		return protoreflect.ValueOfInt32(int32(n)), err
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := toInt(v, math.MinInt64, math.MaxInt64, path)
+		return protoreflect.ValueOfInt64(n), err
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := toInt(v, 0, math.MaxUint32, path)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// Encode gives a *big.Int or a float64 for a uint64 that
+		// overflows int.
+		if b, ok := v.(*big.Int); ok && b.IsUint64() {
+			return protoreflect.ValueOfUint64(b.Uint64()), nil
+		}
+		if f, ok := v.(float64); ok && f >= math.MaxInt64 && f < math.MaxUint64 && f == math.Trunc(f) {
+			return protoreflect.ValueOfUint64(uint64(f)), nil
+		}
+		n, err := toInt(v, 0, math.MaxInt64, path)
+		return protoreflect.ValueOfUint64(uint64(n)), err
+
+	case protoreflect.FloatKind:
+		f, err := toFloat(v, path)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+
+	case protoreflect.DoubleKind:
+		f, err := toFloat(v, path)
+		return protoreflect.ValueOfFloat64(f), err
+
+	case protoreflect.BytesKind:
+		switch v := v.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(v), nil
+		case string:
+			b, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return protoreflect.Value{}, pathError(path, "expected base64 string: %s", err)
+			}
+			return protoreflect.ValueOfBytes(b), nil
+		}
+		return protoreflect.Value{}, mismatch(path, "base64 string", v)
+
+	case protoreflect.EnumKind:
+		if s, ok := v.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(s))
+			if ev == nil {
+				return protoreflect.Value{}, pathError(path, "unknown value %q of %s", s, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := toInt(v, math.MinInt32, math.MaxInt32, path)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		msg := newMessage()
+		if err := d.decodeValue(v, msg.Message(), path); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return msg, nil
+
+	default:
+		return protoreflect.Value{}, pathError(path, "unsupported kind %s", fd.Kind())
+	}
+}
+
+// decodeValue is the inverse of encodeValue.
+func (d *decoder) decodeValue(v interface{}, m protoreflect.Message, path string) error {
+	wk, err := wellKnown(m)
+	if err != nil {
+		return pathError(path, "%s", err)
+	}
+
+	switch wk.(type) {
+	case *timestamppb.Timestamp:
+		t, err := d.decodeTime(v, path)
+		if err != nil {
+			return err
+		}
+
+		wk = timestamppb.New(t)
+
+	case *durationpb.Duration:
+		if wk, err = decodeDuration(v, path); err != nil {
+			return err
+		}
+
+	case *anypb.Any:
+		if wk, err = d.decodeAny(v, path); err != nil {
+			return err
+		}
+
+	case *structpb.Struct:
+		sv, err := decodeJSONValue(v, path)
+		if err != nil {
+			return err
+		}
+		if wk = sv.GetStructValue(); wk == nil {
+			return mismatch(path, "object", v)
+		}
+
+	case *structpb.Value:
+		if wk, err = decodeJSONValue(v, path); err != nil {
+			return err
+		}
+
+	case *structpb.ListValue:
+		lv, ok := asList(v)
+		if !ok {
+			return mismatch(path, "list", v)
+		}
+		sv, err := decodeJSONValue(lv, path)
+		if err != nil {
+			return err
+		}
+		wk = sv.GetListValue()
+
+	default:
+		return d.decodeMessage(v, m, path)
+	}
+
+	if err := setWellKnown(m, wk); err != nil {
+		return pathError(path, "%s", err)
+	}
+	return nil
+}
+
+// decodeJSONValue decodes a value of a Struct, which can be anything that
+// is valid in JSON.
+func decodeJSONValue(v interface{}, path string) (*structpb.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return structpb.NewNullValue(), nil
+
+	case bool:
+		return structpb.NewBoolValue(v), nil
+
+	case string:
+		return structpb.NewStringValue(v), nil
+
+	case map[string]interface{}:
+		s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(v))}
+		for _, k := range sortedKeys(v) {
+			var err error
+			if s.Fields[k], err = decodeJSONValue(v[k], path+"["+strconv.Quote(k)+"]"); err != nil {
+				return nil, err
+			}
+		}
+		return structpb.NewStructValue(s), nil
+
+	case []interface{}:
+		l := &structpb.ListValue{Values: make([]*structpb.Value, len(v))}
+		for i, ev := range v {
+			var err error
+			if l.Values[i], err = decodeJSONValue(ev, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return nil, err
+			}
+		}
+		return structpb.NewListValue(l), nil
+	}
+
+	f, err := toFloat(v, path)
+	if err != nil {
+		return nil, mismatch(path, "JSON value", v)
+	}
+	return structpb.NewNumberValue(f), nil
+}
+
+// decodeTime decodes a timestamp in any of the TimeFormats, or one kept
+// by the decoder.
+func (d *decoder) decodeTime(v interface{}, path string) (time.Time, error) {
+	var t time.Time
+	switch v := v.(type) {
+	case time.Time:
+		t = v
+
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return time.Time{}, pathError(path, "expected RFC 3339 string: %s", err)
+		}
+
+	default:
+		secs, err := toFloat(v, path)
+		if err != nil {
+			return time.Time{}, mismatch(path, "time", v)
+		}
+		if kt, ok := d.keptTime(secs); ok {
+			return kt, nil
+		}
+		return protoutil.FromUnixSeconds(secs), nil
+	}
+
+	if t.Nanosecond()%int(time.Millisecond) == 0 {
+		if kt, ok := d.keptTime(unixMilli(t.UnixMilli())); ok {
+			return kt, nil
+		}
+	}
+	return t, nil
+}
+
+// decodeDuration decodes a duration in the form of protojson or, failing
+// that, as parsed by time.ParseDuration.
+func decodeDuration(v interface{}, path string) (*durationpb.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, mismatch(path, "duration string", v)
+	}
+
+	var d durationpb.Duration
+	if err := protojson.Unmarshal([]byte(strconv.Quote(s)), &d); err == nil {
+		return &d, nil
+	}
+
+	td, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, pathError(path, "expected duration string: %s", err)
+	}
+	return durationpb.New(td), nil
+}
+
+func (d *decoder) decodeAny(v interface{}, path string) (*anypb.Any, error) {
+	mv, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, mismatch(path, "object", v)
+	}
+
+	url, ok := mv[typeKey].(string)
+	if !ok {
+		return nil, mismatch(fieldPath(path, typeKey), "string", mv[typeKey])
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByURL(url)
+	if err != nil {
+		return nil, pathError(path, "resolve %s: %s", url, err)
+	}
+
+	// The map of the inner message is the rest of the map, unless it is a
+	// well-known type without a map form, which is held under "value".
+	msg := mt.New()
+	var inner interface{} = mv["value"]
+	ipath := fieldPath(path, "value")
+	switch msg.Descriptor().FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.Value", "google.protobuf.ListValue":
+	default:
+		rest := make(map[string]interface{}, len(mv))
+		for k, v := range mv {
+			if k != typeKey {
+				rest[k] = v
+			}
+		}
+		inner, ipath = rest, path
+	}
+
+	if err := d.decodeValue(inner, msg, ipath); err != nil {
+		return nil, err
+	}
+
+	a := &anypb.Any{}
+	if err := anypb.MarshalFrom(a, msg.Interface(), proto.MarshalOptions{}); err != nil {
+		return nil, pathError(path, "marshal %s: %s", url, err)
+	}
+	return a, nil
+}
+
+// decodeMapKey parses the string form of a map key.
+func decodeMapKey(k string, fd protoreflect.FieldDescriptor, path string) (protoreflect.MapKey, error) {
+	var (
+		key protoreflect.Value
+		err error
+	)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		key = protoreflect.ValueOfString(k)
+
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(k)
+		key = protoreflect.ValueOfBool(b)
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(k, 10, 32)
+		key = protoreflect.ValueOfInt32(int32(n))
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(k, 10, 64)
+		key = protoreflect.ValueOfInt64(n)
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(k, 10, 32)
+		key = protoreflect.ValueOfUint32(uint32(n))
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var n uint64
+		n, err = strconv.ParseUint(k, 10, 64)
+		key = protoreflect.ValueOfUint64(n)
+
+	default:
+		return protoreflect.MapKey{}, pathError(path, "unsupported key kind %s", fd.Kind())
+	}
+	if err != nil {
+		return protoreflect.MapKey{}, pathError(path, "expected %s key: %s", fd.Kind(), err)
+	}
+
+	return key.MapKey(), nil
+}
+
+// asList returns the list v, which can also be given as an empty map, as
+// an empty Lua table or JavaScript object is.
+func asList(v interface{}) ([]interface{}, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		return v, true
+	case map[string]interface{}:
+		return nil, len(v) == 0
+	default:
+		return nil, false
+	}
+}
+
+// sortedKeys returns the keys of the map in order, so that the first of
+// several errors in the values, which is the one reported, is always the
+// same.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toInt(v interface{}, min, max int64, path string) (int64, error) {
+	if b, ok := v.(*big.Int); ok {
+		if !b.IsInt64() || b.Int64() < min || b.Int64() > max {
+			return 0, pathError(path, "%v is out of range", v)
+		}
+		return b.Int64(), nil
+	}
+
+	var n int64
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = rv.Int()
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, pathError(path, "%v is out of range", v)
+		}
+		n = int64(rv.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return 0, pathError(path, "expected integer, got %v", v)
+		}
+		if f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, pathError(path, "%v is out of range", v)
+		}
+		n = int64(f)
+
+	default:
+		return 0, mismatch(path, "number", v)
+	}
+
+	if n < min || n > max {
+		return 0, pathError(path, "%v is out of range", v)
+	}
+	return n, nil
+}
+
+func toFloat(v interface{}, path string) (float64, error) {
+	if b, ok := v.(*big.Int); ok {
+		f, _ := new(big.Float).SetInt(b).Float64()
+		return f, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+
+	default:
+		return 0, mismatch(path, "number", v)
+	}
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func mismatch(path, want string, v interface{}) error {
+	return pathError(path, "expected %s, got %s", want, typeName(v))
+}
+
+func pathError(path, format string, args ...interface{}) error {
+	if path == "" {
+		return errors.E(errors.WithTextf(format, args...))
+	}
+	return errors.E(errors.WithTextf("%s: "+format, append([]interface{}{path}, args...)...))
+}
+
+// typeName is the name of the type of v as a script would know it.
+func typeName(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	if _, ok := v.(*big.Int); ok {
+		return "number"
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	default:
+		return reflect.TypeOf(v).String()
+	}
+}
@@ -0,0 +1,124 @@
+package structmap_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+	"github.com/sudo-suhas/play-script-engine/sample"
+	"github.com/sudo-suhas/play-script-engine/structmap"
+)
+
+func TestOverwriteWithErrorPath(t *testing.T) {
+	a, err := sample.FeatureTable()
+	if err != nil {
+		t.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+	w, err := structmap.NewAssetWrapper(a)
+	if err != nil {
+		t.Fatalf("NewAssetWrapper() error = %v", err)
+	}
+	m, err := w.Encode(structmap.Format{})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	features := m["data"].(map[string]interface{})["features"].([]interface{})
+	features[2].(map[string]interface{})["entity_name"] = 42
+
+	err = w.OverwriteWith(m)
+	if want := "data.features[2].entity_name: expected string, got number"; err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("OverwriteWith() error = %v, want %q", err, want)
+	}
+}
+
+// TestDecodeErrorDeterministic decodes a map with several bad fields,
+// which must always report the same one.
+func TestDecodeErrorDeterministic(t *testing.T) {
+	m := map[string]interface{}{
+		"urn":         1,
+		"name":        2,
+		"description": 3,
+		"service":     4,
+		"type":        5,
+		"url":         6,
+		"labels":      map[string]interface{}{"b": 1, "a": 2, "c": 3},
+		"owners":      []interface{}{true},
+	}
+
+	var first string
+	for i := 0; i < 50; i++ {
+		err := structmap.Decode(m, &asset.Asset{})
+		if err == nil {
+			t.Fatal("Decode() error = nil, want an error")
+		}
+		if i == 0 {
+			first = err.Error()
+			continue
+		}
+		if err.Error() != first {
+			t.Fatalf("Decode() error = %q, want %q as the first time", err, first)
+		}
+	}
+	if want := "description: expected string, got number"; !strings.HasSuffix(first, want) {
+		t.Errorf("Decode() error = %q, want %q", first, want)
+	}
+}
+
+// TestDecodeEmptyTable decodes the empty Lua table or JavaScript object,
+// which an empty list or map becomes, into repeated and map fields.
+func TestDecodeEmptyTable(t *testing.T) {
+	for name, v := range map[string]interface{}{
+		"map":  map[string]interface{}{},
+		"list": []interface{}{},
+	} {
+		v := v
+		t.Run(name, func(t *testing.T) {
+			a := &asset.Asset{Name: "x"}
+			m := map[string]interface{}{"name": "x", "owners": v, "labels": v}
+			if err := structmap.Decode(m, a); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if a.Name != "x" || len(a.Owners) != 0 || len(a.Labels) != 0 {
+				t.Errorf("Decode() = %v, want no owners and no labels", a)
+			}
+		})
+	}
+}
+
+// TestDecodeKeepsTimes decodes the timestamps of an asset as rounded by
+// the engines back into the asset, which keeps their nanoseconds.
+func TestDecodeKeepsTimes(t *testing.T) {
+	created := time.Date(2022, time.September, 19, 22, 42, 4, 123_456_789, time.UTC)
+	updated := time.Date(2022, time.September, 21, 13, 23, 2, 250_123_456, time.UTC)
+
+	cases := map[string]map[string]interface{}{
+		"unix": {
+			"create_time": protoutil.UnixSeconds(created),
+			"update_time": protoutil.UnixSeconds(updated),
+		},
+		"millis": {
+			"create_time": created.Truncate(time.Millisecond).Format(time.RFC3339Nano),
+			"update_time": updated.Truncate(time.Millisecond),
+		},
+	}
+	for name, m := range cases {
+		m := m
+		t.Run(name, func(t *testing.T) {
+			a := &asset.Asset{CreateTime: timestamppb.New(created), UpdateTime: timestamppb.New(updated)}
+			if err := structmap.Decode(m, a); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got := a.GetCreateTime().AsTime(); !got.Equal(created) {
+				t.Errorf("Decode() create_time = %v, want %v", got, created)
+			}
+			if got := a.GetUpdateTime().AsTime(); !got.Equal(updated) {
+				t.Errorf("Decode() update_time = %v, want %v", got, updated)
+			}
+		})
+	}
+}
@@ -1,33 +1,257 @@
+// Package structmap converts proto messages to and from the maps and
+// slices that script engines work with.
 package structmap
 
 import (
-	"github.com/fatih/structs"
-	"github.com/mitchellh/mapstructure"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
 	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+// TimeFormat is the form timestamps take in the maps, the idiomatic time
+// value of the engine the maps are passed to.
+type TimeFormat int
+
+// Formats of timestamps. Decode accepts each of them.
+const (
+	// TimeRFC3339 is an RFC 3339 string with nanoseconds, for engines
+	// without a time type.
+	TimeRFC3339 TimeFormat = iota
+	// TimeGo is a time.Time in UTC, for engines that convert it to their
+	// time type.
+	TimeGo
+	// TimeUnix is the float64 number of seconds since the Unix epoch, as
+	// returned by os.time in Lua. It has a precision of about a
+	// microsecond and is rounded to the microsecond when decoded, unless
+	// it is the number of a timestamp of the message decoded into.
+	TimeUnix
 )
 
-func AsMap(v interface{}) map[string]interface{} {
-	s := structs.New(v)
-	s.TagName = "json"
-	return s.Map()
+// Format is the form of the values in the maps which differ between the
+// engines the maps are passed to.
+type Format struct {
+	Time TimeFormat
+	// BigInt makes an integer which overflows int a *big.Int, as used by
+	// gojq, instead of a float64 which loses precision above 2^53.
+	BigInt bool
+}
+
+// typeKey is the key holding the type URL in the map of an Any.
+const typeKey = "@type"
+
+// Encode returns the populated fields of the message as a map keyed by the
+// proto field names. The values are limited to the types understood by all
+// the engines:
+//
+//   - Messages are maps and repeated fields are slices.
+//   - Map fields are maps keyed by the string form of the key.
+//   - Integers are ints, or *big.Ints or float64s as per the format if
+//     they overflow int, and floats are float64s.
+//   - Enums are the names of their values and bytes are base64 strings.
+//   - Timestamps take the time format and durations are strings in the
+//     form of protojson, such as "1.5s".
+//   - An Any is the map of the message it holds with the type URL under
+//     "@type".
+//   - A Struct, Value or ListValue is the map, value or slice it holds as
+//     in JSON, with every number a float64.
+func Encode(m proto.Message, f Format) (map[string]interface{}, error) {
+	const op = "structmap.Encode"
+
+	res, err := encodeMessage(m.ProtoReflect(), f)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	return res, nil
 }
 
-func AsStruct(input, output interface{}) error {
-	const op = "structmap.AsStruct"
+func encodeMessage(m protoreflect.Message, f Format) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
 
-	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-		ErrorUnused: true,
-		ZeroFields:  true,
-		Result:      output,
-		TagName:     "json",
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := string(fd.Name())
+		if res[name], err = encodeField(fd, v, f); err != nil {
+			err = errors.E(errors.WithTextf("field %s", name), errors.WithErr(err))
+			return false
+		}
+		return true
 	})
 	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithText("mapstructure config"), errors.WithErr(err))
+		return nil, err
 	}
 
-	if err := dec.Decode(input); err != nil {
-		return errors.E(errors.WithOp(op), errors.WithTextf("decode into %T", output), errors.WithErr(err))
+	return res, nil
+}
+
+func encodeField(fd protoreflect.FieldDescriptor, v protoreflect.Value, f Format) (interface{}, error) {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		res := make([]interface{}, l.Len())
+		for i := range res {
+			var err error
+			if res[i], err = encodeSingular(fd, l.Get(i), f); err != nil {
+				return nil, errors.E(errors.WithTextf("index %d", i), errors.WithErr(err))
+			}
+		}
+		return res, nil
+
+	case fd.IsMap():
+		res := make(map[string]interface{}, v.Map().Len())
+		var err error
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			if res[k.String()], err = encodeSingular(fd.MapValue(), v, f); err != nil {
+				err = errors.E(errors.WithTextf("key %s", k.String()), errors.WithErr(err))
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+
+	default:
+		return encodeSingular(fd, v, f)
+	}
+}
+
+func encodeSingular(fd protoreflect.FieldDescriptor, v protoreflect.Value, f Format) (interface{}, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return v.Bool(), nil
+
+	case protoreflect.StringKind:
+		return v.String(), nil
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n := v.Int()
+		if n < math.MinInt || n > math.MaxInt {
+			if f.BigInt {
+				return new(big.Int).SetInt64(n), nil
+			}
+			return float64(n), nil
+		}
+		return int(n), nil
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n := v.Uint()
+		if n > math.MaxInt {
+			if f.BigInt {
+				return new(big.Int).SetUint64(n), nil
+			}
+			return float64(n), nil
+		}
+		return int(n), nil
+
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float(), nil
+
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return int(v.Enum()), nil
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return encodeValue(v.Message(), f)
+
+	default:
+		return nil, errors.E(errors.WithTextf("unsupported kind %s", fd.Kind()))
+	}
+}
+
+// encodeValue encodes a message held by a field, which is a map unless it
+// is one of the well-known types with a special form.
+func encodeValue(m protoreflect.Message, f Format) (interface{}, error) {
+	wk, err := wellKnown(m)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := wk.(type) {
+	case *timestamppb.Timestamp:
+		return encodeTime(v.AsTime(), f.Time), nil
+
+	case *durationpb.Duration:
+		return formatDuration(v), nil
+
+	case *anypb.Any:
+		inner, err := v.UnmarshalNew()
+		if err != nil {
+			return nil, errors.E(errors.WithTextf("unmarshal %s", v.GetTypeUrl()), errors.WithErr(err))
+		}
+
+		iv, err := encodeValue(inner.ProtoReflect(), f)
+		if err != nil {
+			return nil, err
+		}
+
+		// A Value is held under "value" even if it is an object, as
+		// decodeAny can only tell the forms apart by the type.
+		res, ok := iv.(map[string]interface{})
+		if _, isValue := inner.(*structpb.Value); !ok || isValue {
+			res = map[string]interface{}{"value": iv}
+		}
+		res[typeKey] = v.GetTypeUrl()
+		return res, nil
+
+	case *structpb.Struct:
+		return v.AsMap(), nil
+
+	case *structpb.Value:
+		return v.AsInterface(), nil
+
+	case *structpb.ListValue:
+		return v.AsSlice(), nil
+
+	default:
+		return encodeMessage(m, f)
+	}
+}
+
+func encodeTime(t time.Time, f TimeFormat) interface{} {
+	switch f {
+	case TimeGo:
+		return t
+	case TimeUnix:
+		return protoutil.UnixSeconds(t)
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
+
+// formatDuration formats the duration as protojson does, the seconds with
+// 0, 3, 6 or 9 fractional digits followed by "s", which holds the whole
+// range of a Duration unlike time.Duration.
+func formatDuration(d *durationpb.Duration) string {
+	secs, nanos := d.GetSeconds(), d.GetNanos()
+	sign := ""
+	if secs < 0 || nanos < 0 {
+		sign, secs, nanos = "-", -secs, -nanos
 	}
 
-	return nil
+	s := strings.TrimSuffix(fmt.Sprintf("%s%d.%09d", sign, secs, nanos), "000")
+	s = strings.TrimSuffix(s, "000")
+	s = strings.TrimSuffix(s, ".000")
+	return s + "s"
 }
@@ -0,0 +1,212 @@
+package structmap_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/sample"
+	"github.com/sudo-suhas/play-script-engine/structmap"
+)
+
+var formats = []structmap.Format{
+	{Time: structmap.TimeRFC3339},
+	{Time: structmap.TimeGo},
+	{Time: structmap.TimeUnix},
+	{Time: structmap.TimeRFC3339, BigInt: true},
+}
+
+// TestRoundTrip decodes the map of the sample asset of every data type,
+// with their timestamps, Any data, Structs and maps, back into the same
+// asset. It is decoded into a copy of the asset, as the engines do, which
+// keeps the nanoseconds of its timestamps in TimeUnix.
+func TestRoundTrip(t *testing.T) {
+	samples, err := sample.Assets()
+	if err != nil {
+		t.Fatalf("sample.Assets() error = %v", err)
+	}
+
+	for _, f := range formats {
+		for _, want := range samples {
+			f, want := f, want
+			t.Run(fmt.Sprintf("%d/%t/%s", f.Time, f.BigInt, want.Type), func(t *testing.T) {
+				m, err := structmap.Encode(want, f)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+
+				got := proto.Clone(want).(*asset.Asset)
+				if err := structmap.Decode(m, got); err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				checkEqual(t, got, want)
+			})
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	cases := []struct {
+		d    *durationpb.Duration
+		want string
+	}{
+		{d: &durationpb.Duration{}, want: "0s"},
+		{d: durationpb.New(1500 * time.Millisecond), want: "1.500s"},
+		{d: durationpb.New(-time.Nanosecond), want: "-0.000000001s"},
+		{d: durationpb.New(90 * time.Second), want: "90s"},
+		// Beyond the range of time.Duration.
+		{d: &durationpb.Duration{Seconds: 315_576_000_000, Nanos: 1000}, want: "315576000000.000001s"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.want, func(t *testing.T) {
+			data, err := anypb.New(c.d)
+			if err != nil {
+				t.Fatalf("anypb.New() error = %v", err)
+			}
+
+			m, err := structmap.Encode(&asset.Asset{Data: data}, structmap.Format{})
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got := m["data"].(map[string]interface{})["value"]
+			if got != c.want {
+				t.Errorf("Encode() duration = %v, want %s", got, c.want)
+			}
+
+			// The form is the one of protojson.
+			b, err := protojson.Marshal(c.d)
+			if err != nil {
+				t.Fatalf("protojson.Marshal() error = %v", err)
+			}
+			if string(b) != `"`+c.want+`"` {
+				t.Errorf("protojson.Marshal() = %s, want %q", b, c.want)
+			}
+
+			var a asset.Asset
+			if err := structmap.Decode(m, &a); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			d, err := a.Data.UnmarshalNew()
+			if err != nil {
+				t.Fatalf("UnmarshalNew() error = %v", err)
+			}
+			if !proto.Equal(d, c.d) {
+				t.Errorf("Decode() duration = %v, want %v", d, c.d)
+			}
+		})
+	}
+
+	// A duration formatted by time.Duration is accepted as well.
+	m := map[string]interface{}{"data": map[string]interface{}{
+		"@type": "type.googleapis.com/google.protobuf.Duration",
+		"value": "1m30s",
+	}}
+	var a asset.Asset
+	if err := structmap.Decode(m, &a); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	d, err := a.Data.UnmarshalNew()
+	if err != nil {
+		t.Fatalf("UnmarshalNew() error = %v", err)
+	}
+	if got := d.(*durationpb.Duration).AsDuration(); got != 90*time.Second {
+		t.Errorf("Decode() duration = %v, want 1m30s", got)
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	want := &asset.Asset{Labels: map[string]string{"b": "2", "a": "1", "ünïcödé": "3"}}
+
+	m, err := structmap.Encode(want, structmap.Format{})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	labels, ok := m["labels"].(map[string]interface{})
+	if !ok || len(labels) != 3 || labels["ünïcödé"] != "3" {
+		t.Fatalf("Encode() labels = %v, want a map of the labels", m["labels"])
+	}
+
+	var got asset.Asset
+	if err := structmap.Decode(m, &got); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !proto.Equal(&got, want) {
+		t.Errorf("Decode() = %v, want %v", &got, want)
+	}
+}
+
+// checkEqual compares the assets and their unpacked data, whose bytes
+// depend on the order of the map entries.
+func checkEqual(t *testing.T, got, want *asset.Asset) {
+	t.Helper()
+
+	gotData, err := got.GetData().UnmarshalNew()
+	if err != nil {
+		t.Fatalf("decode data: %v", err)
+	}
+	wantData, err := want.GetData().UnmarshalNew()
+	if err != nil {
+		t.Fatalf("decode sample data: %v", err)
+	}
+	if !proto.Equal(gotData, wantData) {
+		t.Errorf("data = %v, want %v", gotData, wantData)
+	}
+
+	got, want = proto.Clone(got).(*asset.Asset), proto.Clone(want).(*asset.Asset)
+	got.Data, want.Data = nil, nil
+	if !proto.Equal(got, want) {
+		t.Errorf("asset = %v, want %v", got, want)
+	}
+}
+
+// BenchmarkRoundTrip compares converting an asset to a map and back with
+// structmap against a round trip through protojson and encoding/json.
+func BenchmarkRoundTrip(b *testing.B) {
+	a, err := sample.FeatureTable()
+	if err != nil {
+		b.Fatalf("sample.FeatureTable() error = %v", err)
+	}
+
+	b.Run("structmap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m, err := structmap.Encode(a, structmap.Format{})
+			if err != nil {
+				b.Fatalf("Encode() error = %v", err)
+			}
+			var got asset.Asset
+			if err := structmap.Decode(m, &got); err != nil {
+				b.Fatalf("Decode() error = %v", err)
+			}
+		}
+	})
+
+	b.Run("protojson", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bs, err := protojson.Marshal(a)
+			if err != nil {
+				b.Fatalf("protojson.Marshal() error = %v", err)
+			}
+			var m map[string]interface{}
+			if err := json.Unmarshal(bs, &m); err != nil {
+				b.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if bs, err = json.Marshal(m); err != nil {
+				b.Fatalf("json.Marshal() error = %v", err)
+			}
+			var got asset.Asset
+			if err := protojson.Unmarshal(bs, &got); err != nil {
+				b.Fatalf("protojson.Unmarshal() error = %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package structmap
+
+import (
+	"reflect"
+
+	"github.com/sudo-suhas/xgo/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// wellKnown returns the message as the generated type of the well-known
+// type with a special form in the maps, or the message itself if it is not
+// one. A well-known type nested in a dynamic message, such as one
+// registered by protoset, is a dynamic message too and is converted.
+func wellKnown(m protoreflect.Message) (proto.Message, error) {
+	var v proto.Message
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Timestamp":
+		v = &timestamppb.Timestamp{}
+	case "google.protobuf.Duration":
+		v = &durationpb.Duration{}
+	case "google.protobuf.Any":
+		v = &anypb.Any{}
+	case "google.protobuf.Struct":
+		v = &structpb.Struct{}
+	case "google.protobuf.Value":
+		v = &structpb.Value{}
+	case "google.protobuf.ListValue":
+		v = &structpb.ListValue{}
+	default:
+		return m.Interface(), nil
+	}
+
+	if reflect.TypeOf(m.Interface()) == reflect.TypeOf(v) {
+		return m.Interface(), nil
+	}
+
+	b, err := proto.Marshal(m.Interface())
+	if err != nil {
+		return nil, errors.E(errors.WithTextf("convert %s", m.Descriptor().FullName()), errors.WithErr(err))
+	}
+	if err := proto.Unmarshal(b, v); err != nil {
+		return nil, errors.E(errors.WithTextf("convert %s", m.Descriptor().FullName()), errors.WithErr(err))
+	}
+
+	return v, nil
+}
+
+// setWellKnown merges the generated message v, returned by wellKnown, into
+// the message m, converting it if m is a dynamic message.
+func setWellKnown(m protoreflect.Message, v proto.Message) error {
+	if reflect.TypeOf(m.Interface()) == reflect.TypeOf(v) {
+		proto.Merge(m.Interface(), v)
+		return nil
+	}
+
+	b, err := proto.Marshal(v)
+	if err != nil {
+		return errors.E(errors.WithTextf("convert %s", m.Descriptor().FullName()), errors.WithErr(err))
+	}
+	if err := (proto.UnmarshalOptions{Merge: true}).Unmarshal(b, m.Interface()); err != nil {
+		return errors.E(errors.WithTextf("convert %s", m.Descriptor().FullName()), errors.WithErr(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,746 @@
+package tengo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/token"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/sudo-suhas/play-script-engine/protoutil"
+)
+
+// message returns the proto message as seen by the script: an object which
+// reads and writes the fields of the message through protoreflect, so that
+// compiled and dynamic messages are alike. Fields are named by their proto
+// names and only the populated ones are iterated, as in JSON. The fields in
+// fixed are read as the given objects instead and cannot be assigned, which
+// is how the asset exposes its unpacked data.
+//
+// A value assigned to a field is checked against the kind of the field.
+// Timestamps are times, enums the names of their values and bytes are
+// bytes. Repeated and map fields are objects backed by the message which
+// can be indexed, iterated and added to with +, but are not arrays and maps
+// to the builtin functions; copy converts them into one. Reading an unset
+// message, repeated or map field returns an empty one which allocates the
+// field when it is first written, so that asset.lineage.upstreams can be
+// assigned when the asset has no lineage. Assigning a field which does not
+// exist is an error.
+func message(m proto.Message, fixed map[string]tengo.Object) tengo.Object {
+	return &messageObject{m: m.ProtoReflect(), fixed: fixed}
+}
+
+// messageObject is a tengo.Object over a message. The message of an unset
+// field is only allocated in the parent when it is written.
+type messageObject struct {
+	tengo.ObjectImpl
+
+	// m is nil until the field fd of the parent is populated.
+	m      protoreflect.Message
+	parent *messageObject
+	fd     protoreflect.FieldDescriptor
+
+	fixed map[string]tengo.Object
+}
+
+// get returns the message for reading, which is read-only if it has not
+// been allocated.
+func (o *messageObject) get() protoreflect.Message {
+	if o.m != nil {
+		return o.m
+	}
+
+	pm := o.parent.get()
+	if pm.Has(o.fd) {
+		o.m = pm.Get(o.fd).Message()
+		return o.m
+	}
+	return pm.Get(o.fd).Message()
+}
+
+// mutable returns the message for writing, allocating it in the parent.
+func (o *messageObject) mutable() protoreflect.Message {
+	if o.m == nil {
+		o.m = o.parent.mutable().Mutable(o.fd).Message()
+	}
+	return o.m
+}
+
+// allocated returns the message if it is populated in the parent or nil.
+func (o *messageObject) allocated() protoreflect.Message {
+	if o.m == nil && o.parent.get().Has(o.fd) {
+		return o.get()
+	}
+	return o.m
+}
+
+func (o *messageObject) TypeName() string {
+	return string(o.get().Descriptor().FullName())
+}
+
+func (o *messageObject) String() string {
+	keys, fields := o.fields()
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + ": " + fields[k].String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func (o *messageObject) IsFalsy() bool {
+	keys, _ := o.fields()
+	return len(keys) == 0
+}
+
+func (o *messageObject) Equals(another tengo.Object) bool {
+	x, ok := another.(*messageObject)
+	return ok && proto.Equal(o.get().Interface(), x.get().Interface())
+}
+
+// Copy returns an object over a copy of the message which is not part of
+// the asset.
+func (o *messageObject) Copy() tengo.Object {
+	m := o.get()
+	c := m.Type().New()
+	proto.Merge(c.Interface(), m.Interface())
+
+	var fixed map[string]tengo.Object
+	if o.fixed != nil {
+		fixed = make(map[string]tengo.Object, len(o.fixed))
+		for k, v := range o.fixed {
+			fixed[k] = v.Copy()
+		}
+	}
+
+	return &messageObject{m: c, fixed: fixed}
+}
+
+func (o *messageObject) IndexGet(index tengo.Object) (tengo.Object, error) {
+	key, ok := index.(*tengo.String)
+	if !ok {
+		return nil, tengo.ErrInvalidIndexType
+	}
+	if v, ok := o.fixed[key.Value]; ok {
+		return v, nil
+	}
+
+	pm := o.get()
+	fd := pm.Descriptor().Fields().ByName(protoreflect.Name(key.Value))
+	switch {
+	case fd == nil:
+		return tengo.UndefinedValue, nil
+
+	case fd.IsList():
+		return &listObject{parent: o, fd: fd}, nil
+
+	case fd.IsMap():
+		return &mapObject{parent: o, fd: fd}, nil
+
+	case fd.Message() != nil && !protoutil.IsTimestamp(fd):
+		if pm.Has(fd) {
+			return &messageObject{m: pm.Get(fd).Message()}, nil
+		}
+		return &messageObject{parent: o, fd: fd}, nil
+
+	case fd.Message() != nil && !pm.Has(fd):
+		return tengo.UndefinedValue, nil
+
+	default:
+		return toTengo(fd, pm.Get(fd)), nil
+	}
+}
+
+func (o *messageObject) IndexSet(index, val tengo.Object) error {
+	key, ok := index.(*tengo.String)
+	if !ok {
+		return tengo.ErrInvalidIndexType
+	}
+	if _, ok := o.fixed[key.Value]; ok {
+		return fmt.Errorf("%s cannot be replaced, modify its fields instead", key.Value)
+	}
+
+	desc := o.get().Descriptor()
+	fd := desc.Fields().ByName(protoreflect.Name(key.Value))
+	if fd == nil {
+		return fmt.Errorf("unknown field %s of %s", key.Value, desc.FullName())
+	}
+
+	return o.set(fd, val)
+}
+
+// set assigns the value to the field, or clears it if the value is
+// undefined.
+func (o *messageObject) set(fd protoreflect.FieldDescriptor, val tengo.Object) error {
+	if _, ok := val.(*tengo.Undefined); ok {
+		if pm := o.allocated(); pm != nil {
+			pm.Clear(fd)
+		}
+		return nil
+	}
+
+	pm := o.mutable()
+	var (
+		v   protoreflect.Value
+		err error
+	)
+	switch {
+	case fd.IsList():
+		v, err = toList(pm.NewField(fd).List(), fd, val)
+	case fd.IsMap():
+		v, err = toMap(pm.NewField(fd).Map(), fd, val)
+	default:
+		v, err = toProto(fd, val, func() protoreflect.Value { return pm.NewField(fd) })
+	}
+	if err != nil {
+		return err
+	}
+
+	pm.Set(fd, v)
+	return nil
+}
+
+func (o *messageObject) Iterate() tengo.Iterator {
+	_, fields := o.fields()
+	return (&tengo.Map{Value: fields}).Iterate()
+}
+
+func (o *messageObject) CanIterate() bool { return true }
+
+// fields returns the names of the populated fields, in the order they are
+// declared, and their values.
+func (o *messageObject) fields() ([]string, map[string]tengo.Object) {
+	pm := o.get()
+	fds := pm.Descriptor().Fields()
+	keys := make([]string, 0, fds.Len())
+	fields := make(map[string]tengo.Object, fds.Len())
+	for i := 0; i < fds.Len(); i++ {
+		name := string(fds.Get(i).Name())
+		if _, ok := o.fixed[name]; !ok && !pm.Has(fds.Get(i)) {
+			continue
+		}
+
+		v, err := o.IndexGet(&tengo.String{Value: name})
+		if err != nil {
+			continue
+		}
+		keys = append(keys, name)
+		fields[name] = v
+	}
+	return keys, fields
+}
+
+// listObject is a tengo.Object over a repeated field.
+type listObject struct {
+	tengo.ObjectImpl
+
+	parent *messageObject
+	fd     protoreflect.FieldDescriptor
+}
+
+func (o *listObject) list() protoreflect.List {
+	return o.parent.get().Get(o.fd).List()
+}
+
+func (o *listObject) mutable() protoreflect.List {
+	return o.parent.mutable().Mutable(o.fd).List()
+}
+
+// elements returns the elements of the list as seen by the script.
+func (o *listObject) elements() []tengo.Object {
+	l := o.list()
+	res := make([]tengo.Object, l.Len())
+	for i := range res {
+		res[i] = toTengo(o.fd, l.Get(i))
+	}
+	return res
+}
+
+func (o *listObject) TypeName() string { return "repeated-field" }
+
+func (o *listObject) String() string {
+	elems := o.elements()
+	strs := make([]string, len(elems))
+	for i, e := range elems {
+		strs[i] = e.String()
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// BinaryOp supports + with an array or a repeated field, which returns an
+// array of the elements of both.
+func (o *listObject) BinaryOp(op token.Token, rhs tengo.Object) (tengo.Object, error) {
+	if op == token.Add {
+		if elems, ok := elements(rhs); ok {
+			return &tengo.Array{Value: append(o.elements(), elems...)}, nil
+		}
+	}
+	return nil, tengo.ErrInvalidOperator
+}
+
+func (o *listObject) IsFalsy() bool { return o.list().Len() == 0 }
+
+func (o *listObject) Equals(another tengo.Object) bool {
+	elems, ok := elements(another)
+	if !ok || len(elems) != o.list().Len() {
+		return false
+	}
+	for i, e := range o.elements() {
+		if !e.Equals(elems[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy returns an array of copies of the elements.
+func (o *listObject) Copy() tengo.Object {
+	elems := o.elements()
+	for i, e := range elems {
+		elems[i] = e.Copy()
+	}
+	return &tengo.Array{Value: elems}
+}
+
+func (o *listObject) IndexGet(index tengo.Object) (tengo.Object, error) {
+	i, ok := index.(*tengo.Int)
+	if !ok {
+		return nil, tengo.ErrInvalidIndexType
+	}
+
+	l := o.list()
+	if i.Value < 0 || i.Value >= int64(l.Len()) {
+		return tengo.UndefinedValue, nil
+	}
+	return toTengo(o.fd, l.Get(int(i.Value))), nil
+}
+
+func (o *listObject) IndexSet(index, val tengo.Object) error {
+	i, ok := index.(*tengo.Int)
+	if !ok {
+		return tengo.ErrInvalidIndexType
+	}
+	if i.Value < 0 || i.Value >= int64(o.list().Len()) {
+		return tengo.ErrIndexOutOfBounds
+	}
+
+	l := o.mutable()
+	v, err := toProto(o.fd, val, l.NewElement)
+	if err != nil {
+		return fmt.Errorf("%s[%d]: %w", o.fd.Name(), i.Value, err)
+	}
+	l.Set(int(i.Value), v)
+	return nil
+}
+
+func (o *listObject) Iterate() tengo.Iterator {
+	return (&tengo.Array{Value: o.elements()}).Iterate()
+}
+
+func (o *listObject) CanIterate() bool { return true }
+
+// mapObject is a tengo.Object over a map field.
+type mapObject struct {
+	tengo.ObjectImpl
+
+	parent *messageObject
+	fd     protoreflect.FieldDescriptor
+}
+
+func (o *mapObject) get() protoreflect.Map {
+	return o.parent.get().Get(o.fd).Map()
+}
+
+func (o *mapObject) mutable() protoreflect.Map {
+	return o.parent.mutable().Mutable(o.fd).Map()
+}
+
+// entries returns the entries of the map as seen by the script, keyed by
+// the string form of the key.
+func (o *mapObject) entries() map[string]tengo.Object {
+	m := o.get()
+	res := make(map[string]tengo.Object, m.Len())
+	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		res[k.String()] = toTengo(o.fd.MapValue(), v)
+		return true
+	})
+	return res
+}
+
+func (o *mapObject) TypeName() string { return "map-field" }
+
+func (o *mapObject) String() string {
+	entries := o.entries()
+	keys := sortedKeys(entries)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + ": " + entries[k].String()
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func (o *mapObject) IsFalsy() bool { return o.get().Len() == 0 }
+
+func (o *mapObject) Equals(another tengo.Object) bool {
+	entries, ok := mapEntries(another)
+	if !ok || len(entries) != o.get().Len() {
+		return false
+	}
+	for k, v := range o.entries() {
+		if e, ok := entries[k]; !ok || !v.Equals(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy returns a map of copies of the values.
+func (o *mapObject) Copy() tengo.Object {
+	entries := o.entries()
+	for k, v := range entries {
+		entries[k] = v.Copy()
+	}
+	return &tengo.Map{Value: entries}
+}
+
+func (o *mapObject) IndexGet(index tengo.Object) (tengo.Object, error) {
+	key, ok := indexKey(index)
+	if !ok {
+		return nil, tengo.ErrInvalidIndexType
+	}
+	k, err := protoutil.MapKey(o.fd.MapKey(), key)
+	if err != nil {
+		return tengo.UndefinedValue, nil
+	}
+
+	m := o.get()
+	if !m.Has(k) {
+		return tengo.UndefinedValue, nil
+	}
+	return toTengo(o.fd.MapValue(), m.Get(k)), nil
+}
+
+// IndexSet sets the value of the key, or deletes the key if the value is
+// undefined.
+func (o *mapObject) IndexSet(index, val tengo.Object) error {
+	key, ok := indexKey(index)
+	if !ok {
+		return tengo.ErrInvalidIndexType
+	}
+	k, err := protoutil.MapKey(o.fd.MapKey(), key)
+	if err != nil {
+		return fmt.Errorf("%s[%q]: %w", o.fd.Name(), key, err)
+	}
+
+	if _, ok := val.(*tengo.Undefined); ok {
+		if o.get().Has(k) {
+			o.mutable().Clear(k)
+		}
+		return nil
+	}
+
+	m := o.mutable()
+	v, err := toProto(o.fd.MapValue(), val, m.NewValue)
+	if err != nil {
+		return fmt.Errorf("%s[%q]: %w", o.fd.Name(), key, err)
+	}
+	m.Set(k, v)
+	return nil
+}
+
+func (o *mapObject) Iterate() tengo.Iterator {
+	return (&tengo.Map{Value: o.entries()}).Iterate()
+}
+
+func (o *mapObject) CanIterate() bool { return true }
+
+// toTengo returns the value of a field, or of an element of it, as seen by
+// the script.
+func toTengo(fd protoreflect.FieldDescriptor, v protoreflect.Value) tengo.Object {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if v.Bool() {
+			return tengo.TrueValue
+		}
+		return tengo.FalseValue
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return &tengo.Int{Value: v.Int()}
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return &tengo.Int{Value: int64(u)}
+		}
+		return &tengo.Float{Value: float64(v.Uint())}
+
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return &tengo.Float{Value: v.Float()}
+
+	case protoreflect.StringKind:
+		return &tengo.String{Value: v.String()}
+
+	case protoreflect.BytesKind:
+		return &tengo.Bytes{Value: v.Bytes()}
+
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return &tengo.String{Value: string(ev.Name())}
+		}
+		return &tengo.Int{Value: int64(v.Enum())}
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if protoutil.IsTimestamp(fd) {
+			return &tengo.Time{Value: protoutil.TimeOf(v.Message())}
+		}
+		return &messageObject{m: v.Message()}
+	}
+
+	return tengo.UndefinedValue
+}
+
+// toProto converts the object assigned by the script to a field, or to an
+// element of it, as per the kind of the field. newMessage returns the
+// message into which the fields of a map are set.
+func toProto(fd protoreflect.FieldDescriptor, val tengo.Object, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := val.(*tengo.Bool); ok {
+			return protoreflect.ValueOfBool(!b.IsFalsy()), nil
+		}
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := integer(fd, val, math.MinInt32, math.MaxInt32)
+		return protoreflect.ValueOfInt32(int32(i)), err
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := integer(fd, val, math.MinInt64, math.MaxInt64)
+		return protoreflect.ValueOfInt64(i), err
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		i, err := integer(fd, val, 0, math.MaxUint32)
+		return protoreflect.ValueOfUint32(uint32(i)), err
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// A uint64 above 2^63 - 1 is read as a float, which cannot hold
+		// the integer exactly, so it is rejected rather than rounded.
+		if f, ok := val.(*tengo.Float); ok && f.Value >= math.MaxInt64 {
+			return protoreflect.Value{}, fmt.Errorf("%s: %.0f is above 2^63 - 1, the largest %s a script can set", fd.FullName(), f.Value, fd.Kind())
+		}
+		i, err := integer(fd, val, 0, math.MaxInt64)
+		return protoreflect.ValueOfUint64(uint64(i)), err
+
+	case protoreflect.FloatKind:
+		if f, ok := number(val); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+
+	case protoreflect.DoubleKind:
+		if f, ok := number(val); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+
+	case protoreflect.StringKind:
+		if s, ok := val.(*tengo.String); ok {
+			return protoreflect.ValueOfString(s.Value), nil
+		}
+
+	case protoreflect.BytesKind:
+		if b, ok := val.(*tengo.Bytes); ok {
+			return protoreflect.ValueOfBytes(b.Value), nil
+		}
+
+	case protoreflect.EnumKind:
+		if s, ok := val.(*tengo.String); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(s.Value)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+			return protoreflect.Value{}, fmt.Errorf("%s: unknown value %q of %s", fd.FullName(), s.Value, fd.Enum().FullName())
+		}
+		i, err := integer(fd, val, math.MinInt32, math.MaxInt32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), err
+
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return toMessage(fd, val, newMessage)
+	}
+
+	return protoreflect.Value{}, mismatch(fd, val)
+}
+
+func toMessage(fd protoreflect.FieldDescriptor, val tengo.Object, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	if protoutil.IsTimestamp(fd) {
+		t, ok := val.(*tengo.Time)
+		if !ok {
+			return protoreflect.Value{}, mismatch(fd, val)
+		}
+		v := newMessage()
+		protoutil.SetTime(v.Message(), t.Value)
+		return v, nil
+	}
+
+	if o, ok := val.(*messageObject); ok {
+		if got, want := o.get().Descriptor().FullName(), fd.Message().FullName(); got != want {
+			return protoreflect.Value{}, fmt.Errorf("%s must be a %s, got %s", fd.FullName(), want, got)
+		}
+		if pm := o.allocated(); pm != nil {
+			return protoreflect.ValueOfMessage(pm), nil
+		}
+		return newMessage(), nil
+	}
+
+	entries, ok := mapEntries(val)
+	if !ok {
+		return protoreflect.Value{}, mismatch(fd, val)
+	}
+
+	v := newMessage()
+	dst := &messageObject{m: v.Message()}
+	fields := fd.Message().Fields()
+	for _, k := range sortedKeys(entries) {
+		efd := fields.ByName(protoreflect.Name(k))
+		if efd == nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown field %s of %s", k, fd.Message().FullName())
+		}
+		if err := dst.set(efd, entries[k]); err != nil {
+			return protoreflect.Value{}, err
+		}
+	}
+	return v, nil
+}
+
+// toList converts the array assigned by the script to a repeated field.
+func toList(l protoreflect.List, fd protoreflect.FieldDescriptor, val tengo.Object) (protoreflect.Value, error) {
+	elems, ok := elements(val)
+	if !ok {
+		return protoreflect.Value{}, fmt.Errorf("%s must be an array, got %s", fd.FullName(), val.TypeName())
+	}
+
+	for i, e := range elems {
+		v, err := toProto(fd, e, l.NewElement)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("[%d]: %w", i, err)
+		}
+		l.Append(v)
+	}
+	return protoreflect.ValueOfList(l), nil
+}
+
+// toMap converts the map assigned by the script to a map field.
+func toMap(m protoreflect.Map, fd protoreflect.FieldDescriptor, val tengo.Object) (protoreflect.Value, error) {
+	entries, ok := mapEntries(val)
+	if !ok {
+		return protoreflect.Value{}, fmt.Errorf("%s must be a map, got %s", fd.FullName(), val.TypeName())
+	}
+
+	for _, key := range sortedKeys(entries) {
+		k, err := protoutil.MapKey(fd.MapKey(), key)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%s[%q]: %w", fd.FullName(), key, err)
+		}
+		v, err := toProto(fd.MapValue(), entries[key], m.NewValue)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("%s[%q]: %w", fd.FullName(), key, err)
+		}
+		m.Set(k, v)
+	}
+	return protoreflect.ValueOfMap(m), nil
+}
+
+// elements returns the elements of an array or a repeated field.
+func elements(val tengo.Object) ([]tengo.Object, bool) {
+	switch val := val.(type) {
+	case *tengo.Array:
+		return append([]tengo.Object(nil), val.Value...), true
+	case *tengo.ImmutableArray:
+		return append([]tengo.Object(nil), val.Value...), true
+	case *listObject:
+		return val.elements(), true
+	}
+	return nil, false
+}
+
+// mapEntries returns the entries of a map or a map field.
+func mapEntries(val tengo.Object) (map[string]tengo.Object, bool) {
+	switch val := val.(type) {
+	case *tengo.Map:
+		return val.Value, true
+	case *tengo.ImmutableMap:
+		return val.Value, true
+	case *mapObject:
+		return val.entries(), true
+	}
+	return nil, false
+}
+
+func sortedKeys(m map[string]tengo.Object) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// integer returns the int assigned to the field if it is between min and
+// max.
+func integer(fd protoreflect.FieldDescriptor, val tengo.Object, min, max int64) (int64, error) {
+	i, ok := val.(*tengo.Int)
+	if !ok {
+		return 0, mismatch(fd, val)
+	}
+
+	if i.Value < min || i.Value > max {
+		return 0, fmt.Errorf("%s: %d overflows %s", fd.FullName(), i.Value, fd.Kind())
+	}
+	return i.Value, nil
+}
+
+func number(val tengo.Object) (float64, bool) {
+	switch v := val.(type) {
+	case *tengo.Int:
+		return float64(v.Value), true
+	case *tengo.Float:
+		return v.Value, true
+	}
+	return 0, false
+}
+
+// indexKey returns the string form of the index of a map field, which can
+// be a string or an int.
+func indexKey(index tengo.Object) (string, bool) {
+	switch index := index.(type) {
+	case *tengo.String:
+		return index.Value, true
+	case *tengo.Int:
+		return strconv.FormatInt(index.Value, 10), true
+	}
+	return "", false
+}
+
+func mismatch(fd protoreflect.FieldDescriptor, val tengo.Object) error {
+	want := "a " + fd.Kind().String()
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		want = "an int"
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		want = "a float"
+	case protoreflect.BytesKind:
+		want = "bytes"
+	case protoreflect.EnumKind:
+		want = "an enum name"
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		want = "a map"
+		if protoutil.IsTimestamp(fd) {
+			want = "a time"
+		}
+	}
+	return fmt.Errorf("%s must be %s, got %s", fd.FullName(), want, val.TypeName())
+}
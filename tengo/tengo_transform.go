@@ -2,91 +2,164 @@ package tengo
 
 import (
 	"context"
+	_ "embed"
+	"fmt"
+	"io"
 
 	"github.com/d5/tengo/v2"
 	"github.com/d5/tengo/v2/stdlib"
 	"github.com/sudo-suhas/xgo/errors"
 
+	"github.com/sudo-suhas/play-script-engine/engine"
 	"github.com/sudo-suhas/play-script-engine/proto/asset"
-	"github.com/sudo-suhas/play-script-engine/structmap"
 )
 
-var script = []byte(`
-text := import("text")
+//go:embed default.tengo
+var defaultScript string
 
-merge := func(m1, m2) {
-	for k, v in m2 {
-		m1[k] = v
-	}
-	return m1
+func init() {
+	engine.Register("tengo", func(opts engine.Options) (engine.Transformer, error) {
+		t, err := New(opts)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	})
 }
 
-asset.labels = merge({script_engine: "tengo"}, asset.labels)
-
-for e in asset.data.entities {
-	e.labels = merge({catch_phrase: "You talkin' to me?"}, e.labels)
+// Transformer is safe for concurrent use. Each call to T runs a clone of
+// the compiled script so that the globals of one run are not seen by
+// another.
+type Transformer struct {
+	compiled *tengo.Compiled
+	urler    func(string) string
+	limits   engine.Limits
 }
 
-for f in asset.data.features {
-	if f.name == "ongoing_placed_and_waiting_acceptance_orders" || f.name == "ongoing_orders" {
-		f.entity_name = "customer_orders"
-	} else if f.name == "merchant_avg_dispatch_arrival_time_10m" {
-		f.entity_name = "merchant_driver"
-	} else if f.name == "ongoing_accepted_orders" {
-		f.entity_name = "merchant_orders"
+func New(opts engine.Options) (*Transformer, error) {
+	const op = "tengo.New"
+
+	if err := opts.Limits.Supported("tengo", engine.LimitMaxAllocs); err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
-}
 
-asset.owners = append(asset.owners || [], { name: "Big Mom", email: "big.mom@wholecakeisland.com" })
+	script, err := opts.ScriptSource(defaultScript)
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
 
-asset.url = urler(asset.name)
+	s := tengo.NewScript([]byte(script))
+	s.SetImports(modules(opts.Printer()))
+	if opts.Limits.MaxAllocs != 0 {
+		s.SetMaxAllocs(opts.Limits.MaxAllocs)
+	}
 
-for u in asset.lineage.upstreams {
-	u.urn = u.service != "kafka" ? u.urn : text.replace(u.urn, ".yonkou.io", "", -1)
-}
-`)
+	for name, v := range map[string]interface{}{
+		// Placeholders, the asset and the URLer are set before each run.
+		"asset": nil,
+		"urler": nil,
+	} {
+		if err := s.Add(name, v); err != nil {
+			return nil, errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+	}
 
-type Transformer struct {
-	URLer func(string) string
+	compiled, err := s.Compile()
+	if err != nil {
+		return nil, errors.E(errors.WithOp(op), errors.InvalidInput, errors.WithText("compile script"), errors.WithErr(err))
+	}
+
+	return &Transformer{compiled: compiled, urler: opts.URLer, limits: opts.Limits}, nil
 }
 
-func (t *Transformer) T(ctx context.Context, a *asset.Asset) error {
+func (t *Transformer) T(ctx context.Context, a *asset.Asset) (err error) {
 	const op = "tengo.Transform"
 
-	s := tengo.NewScript(script)
-	s.SetImports(stdlib.GetModuleMap(
-		"text", "rand", "times", "fmt", "base64", "json", "math", "enum",
-	))
+	defer engine.Recover(op, "tengo", a.GetUrn(), &err)
 
-	wrapper, err := structmap.NewAssetWrapper(a)
-	if err != nil {
-		return errors.E(errors.WithOp(op), errors.WithErr(err))
-	}
+	a, commit := engine.Stage(a)
 
-	m, err := wrapper.EncodeWithoutTypes()
+	data, err := a.Data.UnmarshalNew()
 	if err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	// tengo turns a panic in the URLer into an error of the script.
+	trap := engine.NewTrap(op, "tengo", a.GetUrn())
+	c := t.compiled.Clone()
 	for name, v := range map[string]interface{}{
-		"asset": m,
-		"urler": stdlib.FuncASRS(func(name string) string {
-			return t.URLer(name)
-		}),
+		"asset": message(a, map[string]tengo.Object{"data": message(data, nil)}),
+		"urler": stdlib.FuncASRS(trap.URLer(t.urler)),
 	} {
-		if err := s.Add(name, v); err != nil {
+		if err := c.Set(name, v); err != nil {
 			return errors.E(errors.WithOp(op), errors.WithErr(err))
 		}
 	}
 
-	res, err := s.RunContext(ctx)
-	if err != nil {
+	if err := c.RunContext(ctx); err != nil {
+		if err := trap.Err(); err != nil {
+			return err
+		}
+		if err := engine.ContextError(ctx); err != nil {
+			return errors.E(errors.WithOp(op), errors.WithErr(err))
+		}
+		if errors.Is(err, tengo.ErrObjectAllocLimit) {
+			return errors.E(errors.WithOp(op), errors.WithErr(
+				engine.LimitExceeded(engine.LimitMaxAllocs, t.limits.MaxAllocs, err),
+			))
+		}
 		return errors.E(errors.WithOp(op), errors.WithText("execute script"), errors.WithErr(err))
 	}
 
-	if err := wrapper.OverwriteWith(res.Get("asset").Map()); err != nil {
+	if err := engine.PackData(a, data); err != nil {
+		return errors.E(errors.WithOp(op), errors.WithErr(err))
+	}
+
+	if err := t.limits.CheckOutput(a); err != nil {
 		return errors.E(errors.WithOp(op), errors.WithErr(err))
 	}
 
+	commit()
+
 	return nil
 }
+
+// modules returns the modules of the standard library available to the
+// script, with the print functions of fmt writing to w.
+func modules(w io.Writer) *tengo.ModuleMap {
+	m := stdlib.GetModuleMap("text", "rand", "times", "base64", "json", "math", "enum")
+
+	fmtModule := make(map[string]tengo.Object, len(stdlib.BuiltinModules["fmt"]))
+	for k, v := range stdlib.BuiltinModules["fmt"] {
+		fmtModule[k] = v
+	}
+	fmtModule["print"] = &tengo.UserFunction{Name: "print", Value: func(args ...tengo.Object) (tengo.Object, error) {
+		fmt.Fprint(w, printArgs(args)...)
+		return nil, nil
+	}}
+	fmtModule["println"] = &tengo.UserFunction{Name: "println", Value: func(args ...tengo.Object) (tengo.Object, error) {
+		fmt.Fprint(w, append(printArgs(args), "\n")...)
+		return nil, nil
+	}}
+	fmtModule["printf"] = &tengo.UserFunction{Name: "printf", Value: func(args ...tengo.Object) (tengo.Object, error) {
+		s, err := fmtModule["sprintf"].Call(args...)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprint(w, printArgs([]tengo.Object{s})...)
+		return nil, nil
+	}}
+	m.AddBuiltinModule("fmt", fmtModule)
+
+	return m
+}
+
+// printArgs converts the arguments of the print functions to strings as
+// the fmt module does.
+func printArgs(args []tengo.Object) []interface{} {
+	a := make([]interface{}, len(args))
+	for i, arg := range args {
+		a[i], _ = tengo.ToString(arg)
+	}
+	return a
+}
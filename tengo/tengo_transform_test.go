@@ -0,0 +1,63 @@
+package tengo_test
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/sudo-suhas/play-script-engine/engine"
+	"github.com/sudo-suhas/play-script-engine/proto/asset"
+	"github.com/sudo-suhas/play-script-engine/tengo"
+)
+
+func TestTransformerUint64(t *testing.T) {
+	cases := []struct {
+		script string
+		in     uint64
+		want   uint64
+		err    string
+	}{
+		{script: `asset.data.value = 9223372036854775807`, in: 1, want: math.MaxInt64},
+		// A uint64 above 2^63 - 1 is read as a float.
+		{script: `asset.data.value = asset.data.value`, in: math.MaxUint64, err: "google.protobuf.UInt64Value.value: 18446744073709551616 is above 2^63 - 1"},
+		{script: `asset.data.value = -1`, in: 1, err: "google.protobuf.UInt64Value.value: -1 overflows uint64"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.script, func(t *testing.T) {
+			tr, err := tengo.New(engine.Options{Script: engine.ScriptString(c.script)})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			data, err := anypb.New(wrapperspb.UInt64(c.in))
+			if err != nil {
+				t.Fatalf("anypb.New() error = %v", err)
+			}
+			a := &asset.Asset{Urn: "urn:uint64", Data: data}
+
+			err = tr.T(context.Background(), a)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Errorf("T() error = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("T() error = %v", err)
+			}
+
+			var got wrapperspb.UInt64Value
+			if err := a.Data.UnmarshalTo(&got); err != nil {
+				t.Fatalf("UnmarshalTo() error = %v", err)
+			}
+			if got.Value != c.want {
+				t.Errorf("T() value = %d, want %d", got.Value, c.want)
+			}
+		})
+	}
+}